@@ -0,0 +1,43 @@
+package fleetingincus
+
+import (
+	"math"
+
+	"fleeting-plugin-incus/incusprov"
+)
+
+// Admission control modes
+const (
+	admissionEnforce = "enforce" // Only create as many instances as fit on the host
+	admissionOff     = "off"     // Create whatever is requested
+)
+
+// admit returns how many more instances fit on the host next to the allocated ones, and the
+// resource that limits them. Host values of zero are treated as unknown and do not limit.
+//
+// CPU and memory are allocations: every instance of this group counts with its full size
+// against the host total times the overcommit ratio. The storage pool must keep
+// incus_storage_pool_min_free percent free, both if every root disk filled up and right now.
+func (g *InstanceGroup) admit(host incusprov.HostResources, allocated int) (fits int, limit string) {
+	fits, limit = math.MaxInt, ""
+	bound := func(resource string, n float64) {
+		if n := int(math.Floor(n)); n < fits {
+			fits, limit = max(n, 0), resource
+		}
+	}
+
+	size := g.size
+	if host.CPUs > 0 && size.cpus > 0 {
+		bound("cpu", float64(host.CPUs)*g.IncusCPUOvercommit/float64(size.cpus)-float64(allocated))
+	}
+	if host.Memory > 0 && size.memory > 0 {
+		bound("memory", float64(host.Memory)*g.IncusMemoryOvercommit/float64(size.memory)-float64(allocated))
+	}
+	if host.PoolTotal > 0 && size.disk > 0 {
+		usable := float64(host.PoolTotal) * float64(100-g.IncusPoolMinFree) / 100
+		bound("disk", usable/float64(size.disk)-float64(allocated))
+		bound("disk_free", (usable-float64(host.PoolUsed))/float64(size.disk))
+	}
+
+	return fits, limit
+}
@@ -0,0 +1,45 @@
+package fleetingincus
+
+import (
+	"math"
+	"testing"
+
+	"fleeting-plugin-incus/incusprov"
+)
+
+func TestAdmit(t *testing.T) {
+	g := &InstanceGroup{
+		IncusCPUOvercommit:    4,
+		IncusMemoryOvercommit: 1,
+		IncusPoolMinFree:      10,
+		size:                  instanceResources{cpus: 4, memory: 8 << 30, disk: 100 << 30},
+	}
+	host := incusprov.HostResources{
+		CPUs:      16,        // 64 vCPUs: 16 instances
+		Memory:    96 << 30,  // 12 instances
+		PoolTotal: 1 << 40,   // 921 GiB usable: 9 root disks
+		PoolUsed:  200 << 30, // 721 GiB free above the threshold: 7 root disks
+	}
+
+	tests := []struct {
+		name      string
+		host      incusprov.HostResources
+		allocated int
+		fits      int
+		limit     string
+	}{
+		{"free space", host, 0, 7, "disk_free"},
+		{"allocated disks", host, 3, 6, "disk"},
+		{"memory", incusprov.HostResources{CPUs: 16, Memory: 96 << 30}, 4, 8, "memory"},
+		{"cpu", incusprov.HostResources{CPUs: 4, Memory: 96 << 30}, 1, 3, "cpu"},
+		{"full", incusprov.HostResources{CPUs: 4}, 5, 0, "cpu"},
+		{"unknown", incusprov.HostResources{}, 100, math.MaxInt, ""},
+	}
+
+	for _, tt := range tests {
+		fits, limit := g.admit(tt.host, tt.allocated)
+		if fits != tt.fits || limit != tt.limit {
+			t.Errorf("%s: admit = %d (%s), want %d (%s)", tt.name, fits, limit, tt.fits, tt.limit)
+		}
+	}
+}
@@ -0,0 +1,120 @@
+package fleetingincus
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"time"
+
+	"fleeting-plugin-incus/incusprov"
+)
+
+// Backend is the instance API the plugin drives. *incusprov.Client implements it against
+// an Incus server; other implementations (e.g. fakes in tests) can be set on InstanceGroup.
+// Errors should wrap one of the incusprov.Err* classes where one applies.
+type Backend interface {
+	// CreateInstance creates and starts an instance, without waiting for the guest to be ready
+	CreateInstance(ctx context.Context, opts incusprov.CreateOptions) error
+	// SnapshotInstance takes a stateful snapshot of a running VM and stops it
+	SnapshotInstance(ctx context.Context, name, snapshot string) error
+	// StartInstance applies the settings of opts to an instance created stopped, and starts it
+	StartInstance(ctx context.Context, opts incusprov.CreateOptions) error
+	// RecycleInstance resets an instance and returns it to the warm pool under opts.Name
+	RecycleInstance(ctx context.Context, name string, opts incusprov.CreateOptions) error
+	// DeleteInstance stops and deletes an instance; a missing instance counts as deleted
+	DeleteInstance(ctx context.Context, name string) error
+	// GetInstance returns metadata and primary address of one instance
+	GetInstance(ctx context.Context, name string) (incusprov.VMInfo, error)
+	// ListInstances returns every instance, keyed by name (without addresses)
+	ListInstances(ctx context.Context) (map[string]incusprov.VMInfo, error)
+	// Exec runs a command inside an instance and returns its exit code
+	Exec(ctx context.Context, name string, command []string) (int, error)
+	// PushFile writes a file into an instance
+	PushFile(ctx context.Context, name string, f incusprov.File) error
+	// GetImageFingerprint returns the fingerprint of the image an alias currently points to
+	GetImageFingerprint(ctx context.Context, alias string) (string, error)
+	// GetHostResources returns CPU and memory of the host (or of the cluster target) and the usage
+	// of the storage pool
+	GetHostResources(ctx context.Context) (incusprov.HostResources, error)
+	// GetClusterMembers returns the cluster members and their load, none on standalone servers
+	GetClusterMembers(ctx context.Context) ([]incusprov.ClusterMember, error)
+}
+
+var _ Backend = (*incusprov.Client)(nil)
+
+// errorClass names the incusprov error class of err, for logs
+func errorClass(err error) string {
+	switch {
+	case errors.Is(err, incusprov.ErrNotFound):
+		return "not_found"
+	case errors.Is(err, incusprov.ErrAlreadyExists):
+		return "already_exists"
+	case errors.Is(err, incusprov.ErrAgentNotReady):
+		return "agent_not_ready"
+	case errors.Is(err, incusprov.ErrQuotaExceeded):
+		return "quota_exceeded"
+	case errors.Is(err, incusprov.ErrImageMissing):
+		return "image_missing"
+	case errors.Is(err, incusprov.ErrTimeout):
+		return "timeout"
+	case errors.Is(err, incusprov.ErrTransient):
+		return "transient"
+	}
+	return "unknown"
+}
+
+// connect opens the Incus connection described by the configuration, scoped to incus_project
+// and creating instances on incus_target
+func (g *InstanceGroup) connect(ctx context.Context) (*incusprov.Client, error) {
+	g.log.Info("🔌 [INIT] Connecting to Incus daemon")
+	client, err := incusprov.Connect(ctx, incusprov.ConnectOptions{
+		URL:              g.IncusRemoteURL,
+		SocketPath:       g.IncusSocketPath,
+		ClientCertPath:   g.IncusClientCert,
+		ClientKeyPath:    g.IncusClientKey,
+		ServerCertPath:   g.IncusServerCert,
+		CACertPath:       g.IncusCACert,
+		OperationTimeout: time.Duration(g.IncusOperationTimeout) * time.Second,
+	})
+	if err != nil {
+		g.log.Error("❌ [INIT] Incus connection failed", "error", err)
+		return nil, err
+	}
+
+	if g.IncusTarget != "" {
+		g.log.Info("🖧 [INIT] Creating instances on cluster target", "target", g.IncusTarget)
+		client = client.UseTarget(g.IncusTarget)
+	}
+
+	if g.IncusProject == "" {
+		return client, nil
+	}
+
+	if g.IncusProjectCreate {
+		limits := map[string]string{}
+		if g.IncusProjectInstances > 0 {
+			limits["limits.instances"] = strconv.Itoa(g.IncusProjectInstances)
+		}
+		if g.IncusProjectCPU > 0 {
+			limits["limits.cpu"] = strconv.Itoa(g.IncusProjectCPU)
+		}
+		if g.IncusProjectMemory != "" {
+			limits["limits.memory"] = g.IncusProjectMemory
+		}
+		if g.IncusProjectDisk != "" {
+			limits["limits.disk"] = g.IncusProjectDisk
+		}
+
+		created, err := client.EnsureProject(ctx, g.IncusProject, "fleeting runners of group "+g.IncusGroup, limits)
+		if err != nil {
+			g.log.Error("❌ [INIT] Project setup failed", "project", g.IncusProject, "error", err)
+			return nil, err
+		}
+		if created {
+			g.log.Info("📁 [INIT] Project created", "project", g.IncusProject, "limits", limits)
+		}
+	}
+
+	g.log.Info("📁 [INIT] Using Incus project", "project", g.IncusProject)
+	return client.UseProject(g.IncusProject), nil
+}
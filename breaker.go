@@ -0,0 +1,110 @@
+package fleetingincus
+
+import (
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// maxBackoff caps the delay between retries
+const maxBackoff = time.Minute
+
+// backoff returns the delay before retry number attempt (1-based): base, 2*base, 4*base, ...
+// capped at maxBackoff, each randomized to 50-100% so failed workers do not retry in lockstep
+func backoff(base time.Duration, attempt int) time.Duration {
+	d := maxBackoff
+	if attempt < 32 && base<<(attempt-1) < maxBackoff {
+		d = base << (attempt - 1)
+	}
+	return d/2 + time.Duration(rand.Int63n(int64(d/2)+1))
+}
+
+// Circuit breaker states
+const (
+	breakerClosed   = "closed"    // Scale-ups are served normally
+	breakerOpen     = "open"      // Scale-ups are refused until the cool-down is over
+	breakerHalfOpen = "half-open" // Cool-down over, one trial instance may be created
+)
+
+// breaker stops scale-ups after repeated creation failures that retrying cannot fix,
+// e.g. a missing image or a full storage pool
+type breaker struct {
+	mu        sync.Mutex
+	threshold int           // Consecutive failures that open the breaker
+	coolDown  time.Duration // How long the breaker stays open
+	failures  int           // Consecutive non-transient creation failures
+	openUntil time.Time     // Zero while closed
+	trial     string        // Instance created to test the waters in half-open state
+}
+
+// state returns the breaker state at now
+func (b *breaker) state(now time.Time) string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch {
+	case b.openUntil.IsZero():
+		return breakerClosed
+	case now.Before(b.openUntil) || b.trial != "":
+		return breakerOpen
+	}
+	return breakerHalfOpen
+}
+
+// retryAfter returns how long the breaker stays open
+func (b *breaker) retryAfter(now time.Time) time.Duration {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return max(b.openUntil.Sub(now), 0).Round(time.Second)
+}
+
+// startTrial records the single instance allowed through in half-open state
+func (b *breaker) startTrial(name string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.trial = name
+}
+
+// success closes the breaker. It reports whether the breaker was open.
+func (b *breaker) success() (closed bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	closed = !b.openUntil.IsZero()
+	b.failures = 0
+	b.openUntil = time.Time{}
+	b.trial = ""
+	return closed
+}
+
+// failure counts a non-transient creation failure. It reports whether the breaker opened.
+func (b *breaker) failure(name string, now time.Time) (opened bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.trial == name {
+		b.trial = ""
+	}
+
+	b.failures++
+	if b.failures < b.threshold {
+		return false
+	}
+
+	// Failures of creations still in flight extend the cool-down without re-opening
+	opened = b.openUntil.IsZero() || !now.Before(b.openUntil)
+	b.openUntil = now.Add(b.coolDown)
+	return opened
+}
+
+// settle ends a trial that neither succeeded nor failed, e.g. because of a shutdown
+func (b *breaker) settle(name string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.trial == name {
+		b.trial = ""
+	}
+}
@@ -0,0 +1,66 @@
+package fleetingincus
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBackoff(t *testing.T) {
+	base := time.Second
+	for attempt, want := range map[int]time.Duration{1: time.Second, 2: 2 * time.Second, 3: 4 * time.Second, 10: maxBackoff, 100: maxBackoff} {
+		for range 100 {
+			d := backoff(base, attempt)
+			if d < want/2 || d > want {
+				t.Fatalf("backoff(%s, %d) = %s, want between %s and %s", base, attempt, d, want/2, want)
+			}
+		}
+	}
+}
+
+func TestBreaker(t *testing.T) {
+	now := time.Now()
+	b := breaker{threshold: 2, coolDown: time.Minute}
+
+	if b.failure("a", now) {
+		t.Fatal("opened below the threshold")
+	}
+	if !b.failure("b", now) {
+		t.Fatal("did not open at the threshold")
+	}
+	if b.failure("c", now) {
+		t.Error("failure while open reported as opening again")
+	}
+	if got := b.state(now); got != breakerOpen {
+		t.Errorf("state %s, want open", got)
+	}
+
+	// After the cool-down exactly one trial is let through
+	later := now.Add(2 * time.Minute)
+	if got := b.state(later); got != breakerHalfOpen {
+		t.Fatalf("state %s after cool-down, want half-open", got)
+	}
+	b.startTrial("trial")
+	if got := b.state(later); got != breakerOpen {
+		t.Errorf("state %s with trial in flight, want open", got)
+	}
+
+	// A failed trial re-opens the breaker
+	if !b.failure("trial", later) {
+		t.Error("failed trial did not re-open")
+	}
+	if got := b.state(later.Add(30 * time.Second)); got != breakerOpen {
+		t.Errorf("state %s after failed trial, want open", got)
+	}
+
+	// A successful one closes it
+	b.startTrial("trial2")
+	if !b.success() {
+		t.Error("success did not report closing")
+	}
+	if got := b.state(later); got != breakerClosed {
+		t.Errorf("state %s after success, want closed", got)
+	}
+	if b.failure("d", later) {
+		t.Error("failure count was not reset by success")
+	}
+}
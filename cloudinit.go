@@ -0,0 +1,100 @@
+package fleetingincus
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"strings"
+	"text/template"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// cloudInitVars are the variables available to cloud-init templates
+type cloudInitVars struct {
+	Name      string // Instance name
+	Group     string // Ownership group (incus_group)
+	PublicKey string // SSH public key in authorized_keys format
+}
+
+// cloudInitTemplates holds the parsed cloud-init templates, keyed by Incus config key
+type cloudInitTemplates map[string]*template.Template
+
+// parseCloudInit loads and parses the configured cloud-init sources. Each source is either
+// inline content or the path of a file containing it.
+func parseCloudInit(sources map[string]string) (cloudInitTemplates, error) {
+	templates := cloudInitTemplates{}
+
+	for key, source := range sources {
+		if source == "" {
+			continue
+		}
+
+		content, err := readInlineOrFile(source)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", key, err)
+		}
+
+		tmpl, err := template.New(key).Option("missingkey=error").Parse(content)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse %s template: %w", key, err)
+		}
+
+		templates[key] = tmpl
+	}
+
+	return templates, nil
+}
+
+// render executes all templates and returns the resulting instance config keys
+func (t cloudInitTemplates) render(vars cloudInitVars) (map[string]string, error) {
+	config := make(map[string]string, len(t))
+
+	for key, tmpl := range t {
+		var buf bytes.Buffer
+		if err := tmpl.Execute(&buf, vars); err != nil {
+			return nil, fmt.Errorf("failed to render %s for '%s': %w", key, vars.Name, err)
+		}
+		config[key] = buf.String()
+	}
+
+	return config, nil
+}
+
+// readInlineOrFile treats a single-line value naming an existing file as a path, anything else as
+// inline content. A single-line value that looks like a path must exist, so a typo fails Init instead
+// of booting every instance with the path as its cloud-init config.
+func readInlineOrFile(value string) (string, error) {
+	if strings.ContainsAny(value, "\n") {
+		return value, nil
+	}
+
+	if _, err := os.Stat(value); err != nil {
+		if strings.HasPrefix(value, "/") || strings.HasPrefix(value, "./") || strings.HasPrefix(value, "../") {
+			return "", err
+		}
+		return value, nil
+	}
+
+	data, err := os.ReadFile(value)
+	if err != nil {
+		return "", err
+	}
+
+	return string(data), nil
+}
+
+// publicKeyFromPrivate derives the authorized_keys line for a PEM/OpenSSH private key file
+func publicKeyFromPrivate(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+
+	signer, err := ssh.ParsePrivateKey(data)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse SSH private key %s: %w", path, err)
+	}
+
+	return strings.TrimSpace(string(ssh.MarshalAuthorizedKey(signer.PublicKey()))), nil
+}
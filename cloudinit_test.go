@@ -0,0 +1,37 @@
+package fleetingincus
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestReadInlineOrFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "user-data.yml")
+	if err := os.WriteFile(path, []byte("#cloud-config\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tt := range []struct {
+		value, want string
+		fails       bool
+	}{
+		{value: path, want: "#cloud-config\n"},
+		{value: "#cloud-config\npackages: [git]\n", want: "#cloud-config\npackages: [git]\n"},
+		{value: "#include https://example.com/user-data", want: "#include https://example.com/user-data"},
+		// Typos in paths must not end up as the config itself
+		{value: path + ".missing", fails: true},
+		{value: "./user-data.yml", fails: true},
+	} {
+		got, err := readInlineOrFile(tt.value)
+		if tt.fails {
+			if err == nil {
+				t.Errorf("%q: accepted as %q, want an error", tt.value, got)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("%q: got %q, %v, want %q", tt.value, got, err, tt.want)
+		}
+	}
+}
@@ -0,0 +1,89 @@
+package fleetingincus
+
+import (
+	"errors"
+	"time"
+
+	"fleeting-plugin-incus/incusprov"
+
+	"gitlab.com/gitlab-org/fleeting/fleeting/provider"
+)
+
+// Deletion retries within one worker; after that Update queues the instance again
+const deleteAttempts = 3
+
+// deleteRetryDelay is the base backoff between deletion attempts (a variable so tests can shorten it)
+var deleteRetryDelay = 5 * time.Second
+
+// queueDelete marks an instance as deleting and hands it to a background worker.
+// An instance still owned by a worker (creating or deleting) is picked up by that
+// worker instead. Must be called with g.m held.
+func (g *InstanceGroup) queueDelete(name string) {
+	g.status[name] = provider.StateDeleting
+	if g.busy[name] {
+		return
+	}
+
+	g.busy[name] = true
+	g.wg.Add(1)
+	go g.deprovision(name)
+}
+
+// release hands an instance back once its worker is done with it
+func (g *InstanceGroup) release(name string) {
+	g.m.Lock()
+	delete(g.busy, name)
+	g.m.Unlock()
+}
+
+// deprovision deletes one instance in the background. It stays StateDeleting until
+// Incus confirms the instance is gone.
+func (g *InstanceGroup) deprovision(name string) {
+	defer g.wg.Done()
+	defer g.release(name)
+
+	// Bounded parallelism (incus_delete_concurrency)
+	select {
+	case g.deleteSlots <- struct{}{}:
+		defer func() { <-g.deleteSlots }()
+	case <-g.ctx.Done():
+		g.log.Warn("🛑 [DELETE] Plugin shutting down, VM deletion postponed", "vm_name", name)
+		return
+	}
+
+	g.deleteInstance(name)
+}
+
+// deleteInstance deletes an instance with retries and reports it deleted once it is gone
+func (g *InstanceGroup) deleteInstance(name string) {
+	for attempt := 1; ; attempt++ {
+		g.log.Info("⏹️ [DELETE] Stopping and deleting VM", "vm_name", name, "attempt", attempt)
+		err := g.Backend.DeleteInstance(g.ctx, name)
+		if err == nil || errors.Is(err, incusprov.ErrNotFound) {
+			break
+		}
+
+		if attempt == deleteAttempts || !incusprov.IsRetryable(err) {
+			// Stays deleting; Update queues it again
+			g.log.Error("❌ [DELETE] VM deletion failed", "vm_name", name, "attempts", attempt, "error_class", errorClass(err), "error", err)
+			return
+		}
+
+		delay := backoff(deleteRetryDelay, attempt)
+		g.log.Warn("🔁 [DELETE] VM deletion failed, retrying", "vm_name", name, "attempt", attempt, "retry_in", delay, "error_class", errorClass(err), "error", err)
+		select {
+		case <-time.After(delay):
+		case <-g.ctx.Done():
+			return
+		}
+	}
+
+	g.m.Lock()
+	g.status[name] = provider.StateDeleted
+	save(g.StateFilePath, g.status, g.members)
+	g.m.Unlock()
+	g.discardKey(name)
+	g.metrics.deleted.Add(1)
+
+	g.log.Info("✅ [DELETE] VM deletion completed", "vm_name", name, "total_deleted", g.metrics.deleted.Load())
+}
@@ -0,0 +1,415 @@
+package fleetingincus
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"fleeting-plugin-incus/incusprov"
+)
+
+var (
+	errFakeNotFound    = fmt.Errorf("Instance not found: %w", incusprov.ErrNotFound)
+	errFakeAgentDown   = fmt.Errorf("VM agent isn't currently running: %w", incusprov.ErrAgentNotReady)
+	errFakeDeleteError = fmt.Errorf("simulated deletion failure: %w", incusprov.ErrTransient)
+)
+
+// fakeBackend is an in-memory Backend. Instances boot instantly unless bootDelay is set;
+// until then Exec and PushFile fail like a VM whose agent is not running yet.
+type fakeBackend struct {
+	mu sync.Mutex
+
+	instances map[string]*fakeInstance
+	nextIP    int
+
+	bootDelay      time.Duration     // Time from creation until the guest agent answers
+	neverBoots     bool              // The guest agent never answers
+	createErr      error             // Returned by every CreateInstance call
+	createErrs     []error           // Returned by the next CreateInstance calls, one each, before createErr
+	leftover       bool              // Failed CreateInstance calls still leave the instance behind
+	startErr       error             // Returned by every StartInstance call
+	deleteFailures map[string]int    // Number of DeleteInstance calls to fail per instance
+	exitCodes      map[string]int    // Exit code of Exec per command (joined with spaces), 0 otherwise
+	images         map[string]string // Image fingerprint per alias; other aliases do not resolve
+	host           incusprov.HostResources
+	hostErr        error                     // Returned by GetHostResources
+	members        []incusprov.ClusterMember // Cluster members; none for a standalone server
+	listed         func()                    // Called once by the next ListInstances, after taking the listing
+
+	creates, starts, deletes int
+	rebuilds, restores       int
+}
+
+type fakeInstance struct {
+	info      incusprov.VMInfo
+	opts      incusprov.CreateOptions // As passed to CreateInstance
+	created   time.Time
+	files     map[string][]byte
+	snapshots []string
+	execs     [][]string // Commands run through Exec
+}
+
+func newFakeBackend() *fakeBackend {
+	return &fakeBackend{
+		instances:      map[string]*fakeInstance{},
+		deleteFailures: map[string]int{},
+	}
+}
+
+// add places an instance into the fake as if created outside the plugin
+func (f *fakeBackend) add(name, group, status string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.nextIP++
+	f.instances[name] = &fakeInstance{
+		info: incusprov.VMInfo{
+			Name:         name,
+			Status:       status,
+			Group:        group,
+			Architecture: "x86_64",
+			ImageOS:      "Ubuntu",
+			Address:      fmt.Sprintf("10.0.0.%d", f.nextIP),
+		},
+		files: map[string][]byte{},
+	}
+}
+
+// setStatus simulates a status change in Incus, e.g. a crash
+func (f *fakeBackend) setStatus(name, status string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.instances[name].info.Status = status
+}
+
+// remove simulates an instance being deleted outside the plugin
+func (f *fakeBackend) remove(name string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	delete(f.instances, name)
+}
+
+func (f *fakeBackend) exists(name string) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	_, ok := f.instances[name]
+	return ok
+}
+
+func (f *fakeBackend) count() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return len(f.instances)
+}
+
+// pooled returns the stopped warm pool instances
+func (f *fakeBackend) pooled() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	var names []string
+	for name, inst := range f.instances {
+		if inst.info.Pool && inst.info.Status == "Stopped" {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// executed returns the commands run in an instance
+func (f *fakeBackend) executed(name string) [][]string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return f.instances[name].execs
+}
+
+func (f *fakeBackend) options(name string) incusprov.CreateOptions {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return f.instances[name].opts
+}
+
+func (f *fakeBackend) snapshots(name string) []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return f.instances[name].snapshots
+}
+
+func (f *fakeBackend) file(name, path string) []byte {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return f.instances[name].files[path]
+}
+
+func (f *fakeBackend) CreateInstance(ctx context.Context, opts incusprov.CreateOptions) error {
+	f.mu.Lock()
+	f.creates++
+	err := f.createErr
+	if len(f.createErrs) > 0 {
+		err, f.createErrs = f.createErrs[0], f.createErrs[1:]
+	}
+	_, dup := f.instances[opts.Name]
+	sourceName, sourceSnapshot, _ := strings.Cut(opts.Source, "/")
+	source, sourceExists := f.instances[sourceName]
+	sourceExists = sourceExists && (sourceSnapshot == "" || slices.Contains(source.snapshots, sourceSnapshot))
+	restorable := sourceExists && slices.Contains(source.snapshots, opts.Restore)
+	f.mu.Unlock()
+
+	if dup {
+		return fmt.Errorf("instance '%s' already exists: %w", opts.Name, incusprov.ErrAlreadyExists)
+	}
+	if err != nil && !f.leftover {
+		return err
+	}
+	if opts.Source != "" && !sourceExists {
+		return fmt.Errorf("source instance '%s' not found: %w", opts.Source, incusprov.ErrNotFound)
+	}
+	if opts.Restore != "" && !restorable {
+		return fmt.Errorf("snapshot '%s' of '%s' not found: %w", opts.Restore, opts.Source, incusprov.ErrNotFound)
+	}
+
+	status := "Running"
+	if opts.Stopped {
+		status = "Stopped"
+	}
+	f.add(opts.Name, opts.Owner.Group, status)
+
+	f.mu.Lock()
+	inst := f.instances[opts.Name]
+	inst.created = time.Now()
+	inst.opts = opts
+	inst.info.Image = opts.Image
+	inst.info.BaseImage = f.images[opts.Image]
+	if opts.Source != "" {
+		inst.info.BaseImage = source.info.BaseImage
+	}
+	inst.info.Pool = opts.Config[incusprov.ConfigKeyPool] != ""
+	inst.info.Location = f.schedule(opts.Target)
+	if opts.Source != "" && sourceSnapshot == "" && !opts.InstanceOnly() {
+		inst.snapshots = slices.Clone(source.snapshots)
+	}
+	if opts.Stopped && opts.Snapshot != "" {
+		inst.snapshots = append(inst.snapshots, opts.Snapshot)
+	}
+	f.mu.Unlock()
+
+	return err
+}
+
+func (f *fakeBackend) SnapshotInstance(ctx context.Context, name, snapshot string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	inst, ok := f.instances[name]
+	if !ok {
+		return errFakeNotFound
+	}
+	inst.snapshots = append(inst.snapshots, snapshot)
+	inst.info.Status = "Stopped"
+	return nil
+}
+
+func (f *fakeBackend) StartInstance(ctx context.Context, opts incusprov.CreateOptions) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.starts++
+	if f.startErr != nil {
+		return f.startErr
+	}
+	inst, ok := f.instances[opts.Name]
+	if !ok {
+		return errFakeNotFound
+	}
+	if inst.info.Status != "Stopped" {
+		return fmt.Errorf("instance '%s' is already running", opts.Name)
+	}
+
+	inst.info.Status = "Running"
+	inst.info.Pool = false
+	inst.created = time.Now()
+	opts.Image, opts.DiskSize = inst.opts.Image, inst.opts.DiskSize
+	inst.opts = opts
+	return nil
+}
+
+func (f *fakeBackend) RecycleInstance(ctx context.Context, name string, opts incusprov.CreateOptions) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	inst, ok := f.instances[name]
+	if !ok {
+		return errFakeNotFound
+	}
+	switch {
+	case opts.Snapshot != "" && slices.Contains(inst.snapshots, opts.Snapshot):
+		f.restores++
+	case opts.Source == "":
+		f.rebuilds++
+	default:
+		return fmt.Errorf("no snapshot to restore: %w", incusprov.ErrNotRecyclable)
+	}
+
+	delete(f.instances, name)
+	f.instances[opts.Name] = inst
+	inst.info.Name = opts.Name
+	inst.info.Status = "Stopped"
+	inst.info.Pool = true
+	inst.info.Image = opts.Image
+	if opts.Source != "" {
+		inst.info.Image = opts.Source
+	}
+	inst.files = map[string][]byte{}
+	return nil
+}
+
+func (f *fakeBackend) DeleteInstance(ctx context.Context, name string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.deletes++
+	if f.deleteFailures[name] > 0 {
+		f.deleteFailures[name]--
+		return errFakeDeleteError
+	}
+
+	delete(f.instances, name)
+	return nil
+}
+
+func (f *fakeBackend) GetInstance(ctx context.Context, name string) (incusprov.VMInfo, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	inst, ok := f.instances[name]
+	if !ok {
+		return incusprov.VMInfo{}, errFakeNotFound
+	}
+
+	info := inst.info
+	if info.Status != "Running" {
+		info.Address = ""
+	}
+	return info, nil
+}
+
+func (f *fakeBackend) ListInstances(ctx context.Context) (map[string]incusprov.VMInfo, error) {
+	f.mu.Lock()
+	vms := make(map[string]incusprov.VMInfo, len(f.instances))
+	for name, inst := range f.instances {
+		info := inst.info
+		info.Address = ""
+		vms[name] = info
+	}
+	listed := f.listed
+	f.listed = nil
+	f.mu.Unlock()
+
+	if listed != nil {
+		listed()
+	}
+	return vms, nil
+}
+
+func (f *fakeBackend) Exec(ctx context.Context, name string, command []string) (int, error) {
+	inst, err := f.agent(name)
+	if err != nil {
+		return -1, err
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	inst.execs = append(inst.execs, command)
+	return f.exitCodes[strings.Join(command, " ")], nil
+}
+
+func (f *fakeBackend) PushFile(ctx context.Context, name string, file incusprov.File) error {
+	inst, err := f.agent(name)
+	if err != nil {
+		return err
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if file.Append {
+		inst.files[file.Path] = append(inst.files[file.Path], file.Content...)
+	} else {
+		inst.files[file.Path] = file.Content
+	}
+	return nil
+}
+
+func (f *fakeBackend) GetHostResources(ctx context.Context) (incusprov.HostResources, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return f.host, f.hostErr
+}
+
+func (f *fakeBackend) GetImageFingerprint(ctx context.Context, alias string) (string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	fingerprint, ok := f.images[alias]
+	if !ok {
+		return "", fmt.Errorf("image alias '%s' not found: %w", alias, incusprov.ErrNotFound)
+	}
+	return fingerprint, nil
+}
+
+func (f *fakeBackend) GetClusterMembers(ctx context.Context) ([]incusprov.ClusterMember, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return slices.Clone(f.members), nil
+}
+
+// schedule returns the member an instance created with target lands on: the target itself,
+// or the first member of the cluster (group). Must be called with f.mu held.
+func (f *fakeBackend) schedule(target string) string {
+	group, inGroup := strings.CutPrefix(target, "@")
+	for _, m := range f.members {
+		if m.Name == target || target == "" || (inGroup && slices.Contains(m.Groups, group)) {
+			return m.Name
+		}
+	}
+	return ""
+}
+
+// located returns the cluster member an instance lives on
+func (f *fakeBackend) located(name string) string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return f.instances[name].info.Location
+}
+
+// agent returns the instance if its guest agent would answer requests
+func (f *fakeBackend) agent(name string) (*fakeInstance, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	inst, ok := f.instances[name]
+	if !ok {
+		return nil, errFakeNotFound
+	}
+	if f.neverBoots || time.Since(inst.created) < f.bootDelay {
+		return nil, errFakeAgentDown
+	}
+	return inst, nil
+}
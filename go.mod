@@ -6,6 +6,7 @@ require (
 	github.com/hashicorp/go-hclog v1.6.3
 	github.com/lxc/incus v0.7.0
 	gitlab.com/gitlab-org/fleeting/fleeting v0.0.0-20240429092009-80a1949a176e
+	golang.org/x/crypto v0.22.0
 )
 
 require (
@@ -26,7 +27,6 @@ require (
 	github.com/pkg/sftp v1.13.6 // indirect
 	github.com/sirupsen/logrus v1.9.3 // indirect
 	github.com/zitadel/oidc/v2 v2.12.0 // indirect
-	golang.org/x/crypto v0.22.0 // indirect
 	golang.org/x/mod v0.17.0 // indirect
 	golang.org/x/net v0.24.0 // indirect
 	golang.org/x/oauth2 v0.19.0 // indirect
@@ -0,0 +1,69 @@
+package incusprov
+
+import (
+	"context"
+	"fmt"
+	"sort"
+)
+
+// ClusterMember is a member of an Incus cluster with the load figures placement cares about.
+// Offline members carry no figures.
+type ClusterMember struct {
+	Name       string
+	Groups     []string // Cluster groups the member belongs to
+	Online     bool
+	CPUs       uint64  // CPU threads
+	Memory     uint64  // Total memory in bytes
+	MemoryFree uint64  // Free memory in bytes
+	Load       float64 // 1 minute load average
+}
+
+// UseTarget returns a client that creates instances on the given cluster member, or on a member
+// of a cluster group with "@group". Other requests are not affected.
+func (c *Client) UseTarget(name string) *Client {
+	return &Client{server: c.server, opTimeout: c.opTimeout, target: name}
+}
+
+// GetClusterMembers returns the members of the cluster, sorted by name, with the load of each
+// online member. It returns no members if the server is not clustered.
+func (c *Client) GetClusterMembers(ctx context.Context) (members []ClusterMember, err error) {
+	if err = ctx.Err(); err != nil {
+		return nil, err
+	}
+	if !c.server.IsClustered() {
+		return nil, nil
+	}
+
+	list, err := c.server.GetClusterMembers()
+	if err != nil {
+		return nil, fmt.Errorf("🖧 [CLUSTER] failed to list cluster members: %w", classify(err))
+	}
+
+	for _, m := range list {
+		member := ClusterMember{Name: m.ServerName, Groups: m.Groups, Online: m.Status == "Online"}
+		if !member.Online {
+			members = append(members, member)
+			continue
+		}
+
+		state, _, err := c.server.GetClusterMemberState(m.ServerName)
+		if err != nil {
+			return nil, fmt.Errorf("🖧 [CLUSTER] failed to get state of cluster member '%s': %w", m.ServerName, classify(err))
+		}
+		resources, err := c.server.UseTarget(m.ServerName).GetServerResources()
+		if err != nil {
+			return nil, fmt.Errorf("🖧 [CLUSTER] failed to get resources of cluster member '%s': %w", m.ServerName, classify(err))
+		}
+
+		member.CPUs = resources.CPU.Total
+		member.Memory = state.SysInfo.TotalRAM
+		member.MemoryFree = state.SysInfo.FreeRAM
+		if len(state.SysInfo.LoadAverages) > 0 {
+			member.Load = state.SysInfo.LoadAverages[0]
+		}
+		members = append(members, member)
+	}
+
+	sort.Slice(members, func(i, j int) bool { return members[i].Name < members[j].Name })
+	return members, nil
+}
@@ -0,0 +1,93 @@
+package incusprov
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"syscall"
+
+	"github.com/lxc/incus/shared/api"
+)
+
+// Error classes. Errors returned by Client wrap the original Incus error together with one
+// of these, so callers can decide with errors.Is whether to retry, fail fast or give up.
+var (
+	ErrNotFound      = errors.New("not found")                // The instance (or other object) does not exist
+	ErrAlreadyExists = errors.New("already exists")           // An instance (or other object) of that name exists already
+	ErrAgentNotReady = errors.New("instance agent not ready") // The guest cannot take exec or file requests yet
+	ErrQuotaExceeded = errors.New("quota exceeded")           // Project limits or storage space are exhausted
+	ErrImageMissing  = errors.New("image missing")            // The image alias does not exist
+	ErrTimeout       = errors.New("timed out")                // A request or operation exceeded its time bound
+	ErrTransient     = errors.New("transient incus error")    // Server or network hiccup, worth retrying
+)
+
+// classifiedError attaches an error class to an Incus error without changing its message
+type classifiedError struct {
+	class error
+	err   error
+}
+
+func (e *classifiedError) Error() string {
+	return e.err.Error()
+}
+
+func (e *classifiedError) Unwrap() []error {
+	return []error{e.class, e.err}
+}
+
+// IsRetryable reports whether an error is expected to go away on its own
+func IsRetryable(err error) bool {
+	return errors.Is(err, ErrTransient) || errors.Is(err, ErrTimeout) || errors.Is(err, ErrAgentNotReady)
+}
+
+// classify maps an Incus API or transport error onto an error class. Unknown errors are
+// returned unchanged.
+func classify(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	class := errorClass(err)
+	if class == nil || errors.Is(err, class) {
+		return err
+	}
+
+	return &classifiedError{class: class, err: err}
+}
+
+func errorClass(err error) error {
+	msg := err.Error()
+
+	switch {
+	case errors.Is(err, context.DeadlineExceeded):
+		return ErrTimeout
+	case strings.Contains(msg, "VM agent isn't currently running"), strings.Contains(msg, "Instance is not running"):
+		return ErrAgentNotReady
+	case strings.Contains(msg, "Reached maximum"), strings.Contains(msg, "no space left on device"):
+		return ErrQuotaExceeded
+	case strings.Contains(msg, "couldn't be found") && strings.Contains(msg, "image"),
+		strings.Contains(msg, "Image not found"):
+		return ErrImageMissing
+	case api.StatusErrorCheck(err, http.StatusNotFound):
+		return ErrNotFound
+	case api.StatusErrorCheck(err, http.StatusConflict), strings.Contains(msg, "already exists"):
+		return ErrAlreadyExists
+	case api.StatusErrorCheck(err, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout):
+		// Not 500: Incus answers invalid config and failed storage or network setup with it as well,
+		// and those fail the same way on every attempt
+		return ErrTransient
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return ErrTimeout
+	}
+	if errors.Is(err, syscall.ECONNREFUSED) || errors.Is(err, syscall.ECONNRESET) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return ErrTransient
+	}
+
+	return nil
+}
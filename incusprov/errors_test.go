@@ -0,0 +1,56 @@
+package incusprov
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"syscall"
+	"testing"
+
+	"github.com/lxc/incus/shared/api"
+)
+
+func TestClassify(t *testing.T) {
+	tests := []struct {
+		err   error
+		class error
+	}{
+		{api.StatusErrorf(http.StatusNotFound, "Instance not found"), ErrNotFound},
+		{api.StatusErrorf(http.StatusNotFound, "Image not found"), ErrImageMissing},
+		{errors.New("The requested image couldn't be found"), ErrImageMissing},
+		{errors.New("VM agent isn't currently running"), ErrAgentNotReady},
+		{api.StatusErrorf(http.StatusBadRequest, "Instance is not running"), ErrAgentNotReady},
+		{errors.New(`Failed checking if instance creation allowed: Reached maximum number of instances in project "ci"`), ErrQuotaExceeded},
+		{errors.New("Failed creating instance from image: write: no space left on device"), ErrQuotaExceeded},
+		{api.StatusErrorf(http.StatusConflict, "Instance \"runner\" already exists"), ErrAlreadyExists},
+		{api.StatusErrorf(http.StatusServiceUnavailable, "Service Unavailable"), ErrTransient},
+		{fmt.Errorf("dial unix /var/lib/incus/unix.socket: %w", syscall.ECONNREFUSED), ErrTransient},
+		{fmt.Errorf("operation cancelled: %w", context.DeadlineExceeded), ErrTimeout},
+	}
+
+	for _, tt := range tests {
+		err := classify(tt.err)
+		if !errors.Is(err, tt.class) {
+			t.Errorf("classify(%q) is not %v", tt.err, tt.class)
+		}
+		if !errors.Is(err, tt.err) || err.Error() != tt.err.Error() {
+			t.Errorf("classify(%q) lost the original error: %v", tt.err, err)
+		}
+	}
+
+	// Unknown errors stay unclassified and are not retried, nor are internal server errors
+	for _, err := range []error{
+		classify(errors.New("Invalid config")),
+		classify(api.StatusErrorf(http.StatusInternalServerError, "Failed creating instance record: Invalid devices")),
+	} {
+		for _, class := range []error{ErrNotFound, ErrAlreadyExists, ErrAgentNotReady, ErrQuotaExceeded, ErrImageMissing, ErrTimeout, ErrTransient} {
+			if errors.Is(err, class) {
+				t.Errorf("%q classified as %v", err, class)
+			}
+		}
+		if IsRetryable(err) {
+			t.Errorf("%q is retryable", err)
+		}
+	}
+}
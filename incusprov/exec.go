@@ -0,0 +1,35 @@
+package incusprov
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/lxc/incus/shared/api"
+)
+
+// Exec runs a command inside the instance and returns its exit code
+func (c *Client) Exec(ctx context.Context, name string, command []string) (exitCode int, err error) {
+	if err = ctx.Err(); err != nil {
+		return -1, err
+	}
+
+	op, err := c.server.ExecInstance(name, api.InstanceExecPost{
+		Command:   command,
+		WaitForWS: true,
+	}, nil)
+	if err != nil {
+		return -1, classify(err)
+	}
+
+	err = c.wait(ctx, op)
+	if err != nil {
+		return -1, err
+	}
+
+	code, ok := op.Get().Metadata["return"].(float64)
+	if !ok {
+		return -1, fmt.Errorf("no exit code returned for %v in '%s'", command, name)
+	}
+
+	return int(code), nil
+}
@@ -1,174 +1,632 @@
 package incusprov
 
 import (
+	"bytes"
+	"cmp"
+	"context"
 	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
 	"time"
 
 	incus "github.com/lxc/incus/client"
 	"github.com/lxc/incus/shared/api"
 )
 
-var (
-	ic incus.InstanceServer
+// Client drives instances on one Incus server (and project). It is safe for concurrent use.
+type Client struct {
+	server    incus.InstanceServer
+	opTimeout time.Duration // Bounds every API request and operation, see ConnectOptions.OperationTimeout
+	target    string        // Cluster member or "@group" new instances are created on, see UseTarget
+}
+
+// Config keys used to tag instances with the plugin instance that owns them
+const (
+	ConfigKeyGroup         = "user.fleeting.group"
+	ConfigKeyPluginVersion = "user.fleeting.plugin_version"
+	ConfigKeyCreatedAt     = "user.fleeting.created_at"
+	ConfigKeyImage         = "user.fleeting.image" // Image alias or copy source the instance was created from
+	ConfigKeyPool          = "user.fleeting.pool"  // Set while the instance waits stopped in a warm pool
+)
+
+// Owner identifies the instance group that created an instance
+type Owner struct {
+	Group         string
+	PluginVersion string
+}
+
+// StoragePool is the pool root disks are created in
+const StoragePool = "default"
+
+// Instance types supported by CreateInstance
+const (
+	TypeVM        = "virtual-machine"
+	TypeContainer = "container"
 )
 
-func ConnectIncus() (err error) {
-	ic, err = incus.ConnectIncusUnix("", nil)
+// CreateOptions describes an instance to create
+type CreateOptions struct {
+	Name     string
+	Type     string // TypeVM (default) or TypeContainer
+	CPU      int    // limits.cpu; 0 keeps the profile's limit
+	Memory   int64  // limits.memory in bytes; 0 keeps the profile's limit
+	Size     string // Incus instance type (e.g. "gce:n1-standard-1") for sizes CPU and Memory do not describe
+	Image    string // Image alias
+	DiskSize string // Root disk size; may be empty for containers to keep the profile's root disk
+	Owner    Owner
+	Config   map[string]string // Extra instance config, e.g. cloud-init.* keys
+	Stopped  bool              // Create without starting, e.g. for a warm pool
+	Source   string            // Copy this instance or snapshot ("instance/snapshot") instead of Image; see InstanceOnly
+	Restore  string            // Stateful snapshot of the copy to restore, which starts it with its memory state
+	Snapshot string            // Snapshot to take right after a Stopped creation, for RecycleInstance
+	Target   string            // Cluster member or "@group" to create the instance on, instead of the client's target
+}
+
+// File is a file pushed into an instance
+type File struct {
+	Path    string
+	Content []byte
+	Mode    int
+	Append  bool
+}
+
+// VMInfo is the subset of an instance's Incus metadata the plugin reconciles against
+type VMInfo struct {
+	Name         string
+	Status       string
+	Group        string
+	Architecture string // Incus architecture name, e.g. "x86_64" or "aarch64"
+	ImageOS      string // image.os property the instance was created from, e.g. "Ubuntu"
+	Image        string // Image alias or copy source the plugin created the instance from
+	BaseImage    string // Fingerprint of the image the instance was created from (volatile.base_image)
+	Pool         bool   // Waiting stopped in a warm pool
+	Location     string // Cluster member the instance lives on; empty on standalone servers
+	Address      string // Primary IPv4 address; only set by GetInstance, and only while running
+}
+
+// ConnectOptions describes how to reach the Incus daemon. With an empty URL the local
+// unix socket is used (SocketPath, or the default socket if empty).
+type ConnectOptions struct {
+	URL            string // Remote HTTPS endpoint, e.g. https://incus.example.com:8443
+	SocketPath     string // Custom unix socket, e.g. /run/incus/unix.socket.user
+	ClientCertPath string // PEM client certificate for remote servers
+	ClientKeyPath  string // PEM client key for remote servers
+	ServerCertPath string // PEM server certificate to pin
+	CACertPath     string // PEM CA certificate the server certificate is signed by
+
+	OperationTimeout time.Duration // Upper bound for each API request and operation (default 60s)
+}
+
+// Connect opens a connection to the Incus daemon
+func Connect(ctx context.Context, opts ConnectOptions) (c *Client, err error) {
+	c = &Client{opTimeout: 60 * time.Second}
+	if opts.OperationTimeout > 0 {
+		c.opTimeout = opts.OperationTimeout
+	}
+
+	// A hung daemon must not block a request forever
+	httpClient := &http.Client{Timeout: c.opTimeout}
+
+	if opts.URL == "" {
+		c.server, err = incus.ConnectIncusUnixWithContext(ctx, opts.SocketPath, &incus.ConnectionArgs{HTTPClient: httpClient})
+		if err != nil {
+			return nil, fmt.Errorf("🔌 [INIT] failed to connect to incus daemon via unix socket '%s': %w", opts.SocketPath, classify(err))
+		}
+
+		return c, nil
+	}
+
+	args := &incus.ConnectionArgs{HTTPClient: httpClient}
+	if args.TLSClientCert, err = readPEM(opts.ClientCertPath); err != nil {
+		return nil, err
+	}
+	if args.TLSClientKey, err = readPEM(opts.ClientKeyPath); err != nil {
+		return nil, err
+	}
+	if args.TLSServerCert, err = readPEM(opts.ServerCertPath); err != nil {
+		return nil, err
+	}
+	if args.TLSCA, err = readPEM(opts.CACertPath); err != nil {
+		return nil, err
+	}
+
+	c.server, err = incus.ConnectIncusWithContext(ctx, opts.URL, args)
 	if err != nil {
-		return fmt.Errorf("🔌 [INIT] failed to connect to incus daemon: %w", err)
+		return nil, fmt.Errorf("🔌 [INIT] failed to connect to incus daemon at '%s': %w", opts.URL, classify(err))
 	}
 
-	return nil
+	return c, nil
 }
 
-func CreateVM(name, size, alias string) (err error) {
-	return CreateVMWithTimeout(name, size, alias, 120) // Default 2 minutes
+// UseProject returns a client scoped to the given Incus project
+func (c *Client) UseProject(name string) *Client {
+	return &Client{server: c.server.UseProject(name), opTimeout: c.opTimeout, target: c.target}
 }
 
-func CreateVMWithTimeout(name, size, alias string, timeoutSeconds int) (err error) {
-	return CreateVMWithDiskSize(name, size, alias, timeoutSeconds, "100GiB")
+// EnsureProject creates the project if it does not exist yet. Images and profiles are shared with
+// the default project, and the given limits.* keys are applied as Incus-enforced quotas.
+func (c *Client) EnsureProject(ctx context.Context, name, description string, limits map[string]string) (created bool, err error) {
+	if err = ctx.Err(); err != nil {
+		return false, err
+	}
+
+	_, _, err = c.server.GetProject(name)
+	if err == nil {
+		return false, nil
+	}
+	if !api.StatusErrorCheck(err, http.StatusNotFound) {
+		return false, fmt.Errorf("📁 [INIT] failed to look up project '%s': %w", name, classify(err))
+	}
+
+	config := map[string]string{
+		"features.images":   "false",
+		"features.profiles": "false",
+	}
+	for key, value := range limits {
+		config[key] = value
+	}
+
+	err = c.server.CreateProject(api.ProjectsPost{
+		Name: name,
+		ProjectPut: api.ProjectPut{
+			Config:      config,
+			Description: description,
+		},
+	})
+	if err != nil {
+		return false, fmt.Errorf("📁 [INIT] failed to create project '%s': %w", name, classify(err))
+	}
+
+	return true, nil
 }
 
-func CreateVMWithDiskSize(name, size, alias string, timeoutSeconds int, diskSize string) (err error) {
+// readPEM reads an optional PEM file, returning "" for an empty path
+func readPEM(path string) (string, error) {
+	if path == "" {
+		return "", nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("🔑 [INIT] failed to read certificate file '%s': %w", path, err)
+	}
+
+	return string(data), nil
+}
+
+// CreateInstance creates and starts an instance. It returns once Incus reports the instance
+// started; waiting for the guest system to become ready is up to the caller.
+func (c *Client) CreateInstance(ctx context.Context, opts CreateOptions) (err error) {
+	name, alias := opts.Name, opts.Image
+	if opts.Type == "" {
+		opts.Type = TypeVM
+	}
+
+	config := opts.config()
+	config[ConfigKeyCreatedAt] = time.Now().UTC().Format(time.RFC3339)
+	config[ConfigKeyImage] = alias
+	if opts.Source != "" {
+		config[ConfigKeyImage] = opts.Source
+	}
+
+	devices := map[string]map[string]string{}
+
+	switch opts.Type {
+	case TypeVM:
+		// VMs always get their own root disk of the requested size
+		devices["root"] = map[string]string{
+			"type": "disk",
+			"path": "/",
+			"pool": StoragePool,
+			"size": opts.DiskSize,
+		}
+	case TypeContainer:
+		// Docker inside a system container needs nesting
+		config["security.nesting"] = "true"
+
+		// A container's root disk size is a quota on the shared pool; only set it when
+		// requested, the profile's root disk is used otherwise (e.g. on "dir" pools without quotas)
+		if opts.DiskSize != "" {
+			devices["root"] = map[string]string{
+				"type": "disk",
+				"path": "/",
+				"pool": StoragePool,
+				"size": opts.DiskSize,
+			}
+		}
+	default:
+		return fmt.Errorf("🔨 [CREATE] unsupported instance type '%s' for '%s'", opts.Type, name)
+	}
+
 	req := api.InstancesPost{
 		Name: name,
 		Source: api.InstanceSource{
 			Type:  "image",
 			Alias: alias,
 		},
-		Type:         "virtual-machine",
-		InstanceType: size,
-		Start:        true,
+		Type:         api.InstanceType(opts.Type),
+		InstanceType: opts.Size,
+		Start:        !opts.Stopped && opts.Restore == "",
 		InstancePut: api.InstancePut{
-			Devices: map[string]map[string]string{
-				"root": {
-					"type": "disk",
-					"path": "/",
-					"pool": "default",
-					"size": diskSize,
-				},
-			},
+			Config:  config,
+			Devices: devices,
 		},
 	}
+	if opts.Source != "" {
+		// Config and devices given here are applied on top of the source's
+		req.Source = api.InstanceSource{
+			Type:         "copy",
+			Source:       opts.Source,
+			InstanceOnly: opts.InstanceOnly(),
+		}
+	}
 
-	// Create the instance
-	op, err := ic.CreateInstance(req)
+	if err = ctx.Err(); err != nil {
+		return err
+	}
+
+	// Create the instance, on the requested cluster member if any
+	server := c.server
+	if target := cmp.Or(opts.Target, c.target); target != "" {
+		server = server.UseTarget(target)
+	}
+	op, err := server.CreateInstance(req)
 	if err != nil {
-		return fmt.Errorf("🔨 [CREATE] failed to create VM '%s' with image '%s': %w", name, alias, err)
+		return fmt.Errorf("🔨 [CREATE] failed to create VM '%s' with image '%s': %w", name, alias, classify(err))
 	}
 
 	// Wait for creation to complete
-	err = op.Wait()
+	err = c.wait(ctx, op)
 	if err != nil {
-		return fmt.Errorf("⏰ [CREATE] failed to wait for VM '%s' creation: %w", name, err)
+		return fmt.Errorf("⏰ [CREATE] failed to wait for VM '%s' creation: %w", name, classify(err))
 	}
 
-	// Wait for system to be ready
-	maxRetries := timeoutSeconds / 2 // Check every 2 seconds
-	for retry := 1; retry <= maxRetries; retry++ {
-		time.Sleep(2 * time.Second)
-
-		op, err = ic.ExecInstance(name, api.InstanceExecPost{
-			Command:   []string{"systemctl", "is-system-running", "--wait"},
-			WaitForWS: true,
-		}, nil)
-
-		if err != nil && err.Error() == "VM agent isn't currently running" {
-			// VM agent not ready yet, continue waiting
-			continue
-		} else if err != nil {
-			return fmt.Errorf("⚠️ [CREATE] failed to check system status for VM '%s' (retry %d/%d): %w", name, retry, maxRetries, err)
+	if opts.Stopped && opts.Snapshot != "" {
+		op, err = c.server.CreateInstanceSnapshot(name, api.InstanceSnapshotsPost{Name: opts.Snapshot})
+		if err != nil {
+			return fmt.Errorf("📸 [CREATE] failed to snapshot VM '%s': %w", name, classify(err))
+		}
+		if err = c.wait(ctx, op); err != nil {
+			return fmt.Errorf("⏰ [CREATE] failed to wait for VM '%s' snapshot: %w", name, classify(err))
 		}
+	}
 
-		err = op.Wait()
-		if err != nil && err.Error() == "VM agent isn't currently running" {
-			// VM agent not ready yet, continue waiting
-			continue
-		} else if err != nil {
-			return fmt.Errorf("⚠️ [CREATE] system not ready for VM '%s' (retry %d/%d): %w", name, retry, maxRetries, err)
+	if opts.Restore != "" {
+		op, err = c.server.UpdateInstance(name, api.InstancePut{Restore: opts.Restore, Stateful: true}, "")
+		if err != nil {
+			return fmt.Errorf("⏪ [CREATE] failed to restore VM '%s' from snapshot '%s': %w", name, opts.Restore, classify(err))
+		}
+		if err = c.wait(ctx, op); err != nil {
+			return fmt.Errorf("⏰ [CREATE] failed to wait for VM '%s' restore: %w", name, classify(err))
 		}
+	}
 
-		// System is ready
-		break
+	return nil
+}
+
+// SnapshotInstance takes a stateful snapshot of a running VM (which needs migration.stateful)
+// and stops it, so copies can be restored from the snapshot
+func (c *Client) SnapshotInstance(ctx context.Context, name, snapshot string) (err error) {
+	if err = ctx.Err(); err != nil {
+		return err
 	}
 
-	return
+	op, err := c.server.CreateInstanceSnapshot(name, api.InstanceSnapshotsPost{Name: snapshot, Stateful: true})
+	if err != nil {
+		return fmt.Errorf("📸 [SNAPSHOT] failed to snapshot VM '%s': %w", name, classify(err))
+	}
+	if err = c.wait(ctx, op); err != nil {
+		return fmt.Errorf("⏰ [SNAPSHOT] failed to wait for VM '%s' snapshot: %w", name, classify(err))
+	}
+
+	// The snapshot holds the state, nothing to shut down gracefully
+	op, err = c.server.UpdateInstanceState(name, api.InstanceStatePut{Action: "stop", Force: true, Timeout: -1}, "")
+	if err != nil {
+		return fmt.Errorf("⏹️ [SNAPSHOT] failed to stop VM '%s': %w", name, classify(err))
+	}
+	if err = c.wait(ctx, op); err != nil {
+		return fmt.Errorf("⏰ [SNAPSHOT] failed to wait for VM '%s' to stop: %w", name, classify(err))
+	}
+
+	return nil
 }
 
-func DeleteVM(name string) (err error) {
-	// First check if instance exists
-	_, _, err = ic.GetInstanceFull(name)
+// InstanceOnly reports whether a copy leaves the source's snapshots behind. Only a copy that
+// restores one of them needs them; copying all snapshots of a golden instance for every runner
+// costs time and storage.
+func (opts CreateOptions) InstanceOnly() bool {
+	return opts.Restore == ""
+}
+
+// config returns the instance config for opts: the extra config, ownership tags and limits
+func (opts CreateOptions) config() map[string]string {
+	config := map[string]string{}
+	for key, value := range opts.Config {
+		config[key] = value
+	}
+
+	// Ownership tags, so Decrease and cleanup never touch foreign instances
+	config[ConfigKeyGroup] = opts.Owner.Group
+	config[ConfigKeyPluginVersion] = opts.Owner.PluginVersion
+
+	if opts.CPU > 0 {
+		config["limits.cpu"] = strconv.Itoa(opts.CPU)
+	}
+	if opts.Memory > 0 {
+		config["limits.memory"] = strconv.FormatInt(opts.Memory>>20, 10) + "MiB"
+	}
+
+	return config
+}
+
+// StartInstance starts an instance created stopped (see CreateOptions.Stopped). The config,
+// ownership tags and limits of opts are applied first and the warm pool tag is removed; name,
+// type, image and disk size are those the instance was created with.
+func (c *Client) StartInstance(ctx context.Context, opts CreateOptions) (err error) {
+	name := opts.Name
+	if err = ctx.Err(); err != nil {
+		return err
+	}
+
+	inst, etag, err := c.server.GetInstance(name)
 	if err != nil {
-		return fmt.Errorf("🔍 [DELETE] failed to find VM '%s': %w", name, err)
+		return fmt.Errorf("🔍 [CREATE] failed to find VM '%s': %w", name, classify(err))
+	}
+
+	put := inst.Writable()
+	if put.Config == nil {
+		put.Config = map[string]string{}
+	}
+	for key, value := range opts.config() {
+		put.Config[key] = value
 	}
+	delete(put.Config, ConfigKeyPool)
 
-	// Stop the instance
-	reqState := api.InstanceStatePut{
-		Action:  "stop",
-		Timeout: -1,
+	op, err := c.server.UpdateInstance(name, put, etag)
+	if err != nil {
+		return fmt.Errorf("⚙️ [CREATE] failed to configure VM '%s': %w", name, classify(err))
+	}
+	if err = c.wait(ctx, op); err != nil {
+		return fmt.Errorf("⏰ [CREATE] failed to wait for VM '%s' configuration: %w", name, classify(err))
 	}
 
-	op, err := ic.UpdateInstanceState(name, reqState, "")
+	op, err = c.server.UpdateInstanceState(name, api.InstanceStatePut{Action: "start", Timeout: -1}, "")
 	if err != nil {
-		return fmt.Errorf("⏹️ [DELETE] failed to stop VM '%s': %w", name, err)
+		return fmt.Errorf("▶️ [CREATE] failed to start VM '%s': %w", name, classify(err))
+	}
+	if err = c.wait(ctx, op); err != nil {
+		return fmt.Errorf("⏰ [CREATE] failed to wait for VM '%s' to start: %w", name, classify(err))
 	}
 
-	err = op.Wait()
+	return nil
+}
+
+// DeleteInstance stops and deletes an instance. An instance that no longer exists counts as deleted.
+func (c *Client) DeleteInstance(ctx context.Context, name string) (err error) {
+	if err = ctx.Err(); err != nil {
+		return err
+	}
+
+	// First check if instance exists
+	inst, _, err := c.server.GetInstanceFull(name)
+	if api.StatusErrorCheck(err, http.StatusNotFound) {
+		// Already gone, e.g. removed by an operator or an earlier attempt
+		return nil
+	}
 	if err != nil {
-		return fmt.Errorf("⏰ [DELETE] failed to wait for VM '%s' to stop: %w", name, err)
+		return fmt.Errorf("🔍 [DELETE] failed to find VM '%s': %w", name, classify(err))
+	}
+
+	// Stop the instance (an operator may already have stopped it)
+	if inst.IsActive() {
+		reqState := api.InstanceStatePut{
+			Action:  "stop",
+			Timeout: -1,
+		}
+		if inst.Type == TypeContainer {
+			// Containers are disposable and have no guest shutdown to wait for
+			reqState.Force = true
+		}
+
+		op, err := c.server.UpdateInstanceState(name, reqState, "")
+		if err != nil {
+			return fmt.Errorf("⏹️ [DELETE] failed to stop VM '%s': %w", name, classify(err))
+		}
+
+		err = c.wait(ctx, op)
+		if err != nil && !reqState.Force && ctx.Err() == nil {
+			// The guest ignored the shutdown request within the operation timeout; it is discarded anyway
+			reqState.Force = true
+			op, err = c.server.UpdateInstanceState(name, reqState, "")
+			if err == nil {
+				err = c.wait(ctx, op)
+			}
+		}
+		if err != nil {
+			return fmt.Errorf("⏰ [DELETE] failed to wait for VM '%s' to stop: %w", name, classify(err))
+		}
 	}
 
 	// Delete the instance
-	op, err = ic.DeleteInstance(name)
+	op, err := c.server.DeleteInstance(name)
 	if err != nil {
-		return fmt.Errorf("🗑️ [DELETE] failed to delete VM '%s': %w", name, err)
+		return fmt.Errorf("🗑️ [DELETE] failed to delete VM '%s': %w", name, classify(err))
 	}
 
-	err = op.Wait()
+	err = c.wait(ctx, op)
 	if err != nil {
-		return fmt.Errorf("⏰ [DELETE] failed to wait for VM '%s' deletion: %w", name, err)
+		return fmt.Errorf("⏰ [DELETE] failed to wait for VM '%s' deletion: %w", name, classify(err))
 	}
 
 	return
 }
 
-func GetVM(name string) (internalIP string, err error) {
-	inst, _, err := ic.GetInstanceFull(name)
-	if err != nil {
-		err = fmt.Errorf("🔍 [CONNECT] failed to get VM info for '%s': %w", name, err)
+// GetInstance returns status, ownership, platform metadata and address of a single instance
+func (c *Client) GetInstance(ctx context.Context, name string) (info VMInfo, err error) {
+	if err = ctx.Err(); err != nil {
 		return
 	}
 
-	if !inst.IsActive() {
-		err = fmt.Errorf("🚫 [CONNECT] VM '%s' is not active", name)
+	inst, _, err := c.server.GetInstanceFull(name)
+	if err != nil {
+		err = fmt.Errorf("🔍 [CONNECT] failed to get VM info for '%s': %w", name, classify(err))
 		return
 	}
 
-	if inst.State == nil || inst.State.Network == nil {
-		err = fmt.Errorf("🌐 [CONNECT] no network information available for VM '%s'", name)
-		return
+	info = newVMInfo(inst.Instance)
+	if inst.IsActive() && inst.State != nil {
+		info.Address = primaryAddress(inst)
 	}
 
-	// Find the primary IP address
-	for netName, net := range inst.State.Network {
-		if net.Type == "loopback" {
+	return
+}
+
+// primaryAddress finds the instance's primary IPv4 address
+func primaryAddress(inst *api.InstanceFull) string {
+	// Containers report interfaces under their NIC device names (eth0, ...), so prefer those;
+	// VM guests rename interfaces (enp5s0, ...) and fall through to the generic scan below
+	var preferred []string
+	for devName, dev := range inst.ExpandedDevices {
+		if dev["type"] != "nic" {
 			continue
 		}
+		if dev["name"] != "" {
+			devName = dev["name"]
+		}
+		preferred = append(preferred, devName)
+	}
+	sort.Strings(preferred)
 
-		for _, addr := range net.Addresses {
-			if addr.Family == "inet" && netName != "docker0" {
-				internalIP = addr.Address
-				return
-			}
+	for _, netName := range preferred {
+		if ip := primaryIPv4(netName, inst.State.Network[netName]); ip != "" {
+			return ip
+		}
+	}
+
+	// Find the primary IP address
+	netNames := make([]string, 0, len(inst.State.Network))
+	for netName := range inst.State.Network {
+		netNames = append(netNames, netName)
+	}
+	sort.Strings(netNames)
+
+	for _, netName := range netNames {
+		if ip := primaryIPv4(netName, inst.State.Network[netName]); ip != "" {
+			return ip
+		}
+	}
+
+	return ""
+}
+
+// PushFile writes a file into the instance, creating the parent directory if needed.
+// With Append the content is appended to an existing file instead of replacing it.
+func (c *Client) PushFile(ctx context.Context, name string, f File) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	dir := filepath.Dir(f.Path)
+	if _, _, err := c.server.GetInstanceFile(name, dir); err != nil {
+		err = c.server.CreateInstanceFile(name, dir, incus.InstanceFileArgs{
+			Type: "directory",
+			Mode: 0755,
+		})
+		if err != nil {
+			return fmt.Errorf("📁 [CREATE] failed to create directory '%s' in VM '%s': %w", dir, name, classify(err))
+		}
+	}
+
+	writeMode := "overwrite"
+	if f.Append {
+		writeMode = "append"
+	}
+
+	err := c.server.CreateInstanceFile(name, f.Path, incus.InstanceFileArgs{
+		Content:   bytes.NewReader(f.Content),
+		Type:      "file",
+		Mode:      f.Mode,
+		WriteMode: writeMode,
+	})
+	if err != nil {
+		return fmt.Errorf("📄 [CREATE] failed to push file '%s' to VM '%s': %w", f.Path, name, classify(err))
+	}
+
+	return nil
+}
+
+// primaryIPv4 returns the first global IPv4 address of an interface, skipping loopback and the
+// bridges Docker creates inside the guest
+func primaryIPv4(netName string, net api.InstanceStateNetwork) string {
+	if net.Type == "loopback" || netName == "docker0" || strings.HasPrefix(netName, "br-") || strings.HasPrefix(netName, "veth") {
+		return ""
+	}
+
+	for _, addr := range net.Addresses {
+		if addr.Family == "inet" && addr.Scope == "global" {
+			return addr.Address
 		}
 	}
 
-	err = fmt.Errorf("🌐 [CONNECT] no suitable IP address found for VM '%s'", name)
+	return ""
+}
+
+// ListInstances returns status and ownership of every instance known to Incus, keyed by name
+func (c *Client) ListInstances(ctx context.Context) (vms map[string]VMInfo, err error) {
+	if err = ctx.Err(); err != nil {
+		return
+	}
+
+	insts, err := c.server.GetInstances(api.InstanceTypeAny)
+	if err != nil {
+		err = fmt.Errorf("📋 [UPDATE] failed to list instances: %w", classify(err))
+		return
+	}
+
+	vms = make(map[string]VMInfo, len(insts))
+	for _, inst := range insts {
+		vms[inst.Name] = newVMInfo(inst)
+	}
+
 	return
 }
 
-// VMExists checks if a VM exists in Incus (regardless of network status)
-func VMExists(name string) bool {
-	_, _, err := ic.GetInstanceFull(name)
-	return err == nil
+func newVMInfo(inst api.Instance) VMInfo {
+	// Standalone servers report "none"
+	location := inst.Location
+	if location == "none" {
+		location = ""
+	}
+
+	return VMInfo{
+		Name:         inst.Name,
+		Status:       inst.Status,
+		Group:        inst.Config[ConfigKeyGroup],
+		Architecture: inst.Architecture,
+		ImageOS:      inst.Config["image.os"],
+		Image:        inst.Config[ConfigKeyImage],
+		BaseImage:    inst.Config["volatile.base_image"],
+		Pool:         inst.Config[ConfigKeyPool] != "",
+		Location:     location,
+	}
+}
+
+// wait waits for an operation to finish, bounded by ctx and the operation timeout.
+// If ctx ends first, the operation is cancelled on the server as well.
+func (c *Client) wait(ctx context.Context, op incus.Operation) error {
+	ctx, cancel := context.WithTimeout(ctx, c.opTimeout)
+	defer cancel()
+
+	err := op.WaitContext(ctx)
+	if err != nil && ctx.Err() != nil {
+		// Best effort, not every operation is cancellable
+		_ = op.Cancel()
+		return classify(fmt.Errorf("operation %s cancelled: %w", op.Get().ID, ctx.Err()))
+	}
+
+	return classify(err)
 }
@@ -0,0 +1,104 @@
+package incusprov
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/lxc/incus/shared/api"
+)
+
+// ErrNotRecyclable is returned by RecycleInstance for instances without the snapshot to restore
+// that cannot be rebuilt either, because they were copied from another instance
+var ErrNotRecyclable = errors.New("instance cannot be recycled")
+
+// RecycleInstance stops an instance, resets its root disk and puts it back into a warm pool
+// under opts.Name. The disk is restored from the snapshot opts.Snapshot if the instance has one,
+// otherwise it is rebuilt from opts.Image. The instance is tagged for the pool before it is
+// renamed, so a failure never leaves an untagged instance under the new name.
+func (c *Client) RecycleInstance(ctx context.Context, name string, opts CreateOptions) (err error) {
+	if err = ctx.Err(); err != nil {
+		return err
+	}
+
+	inst, _, err := c.server.GetInstance(name)
+	if err != nil {
+		return fmt.Errorf("🔍 [RECYCLE] failed to find VM '%s': %w", name, classify(err))
+	}
+
+	// The disk is reset anyway, no need for a clean shutdown
+	if inst.IsActive() {
+		op, err := c.server.UpdateInstanceState(name, api.InstanceStatePut{Action: "stop", Force: true, Timeout: -1}, "")
+		if err != nil {
+			return fmt.Errorf("⏹️ [RECYCLE] failed to stop VM '%s': %w", name, classify(err))
+		}
+		if err = c.wait(ctx, op); err != nil {
+			return fmt.Errorf("⏰ [RECYCLE] failed to wait for VM '%s' to stop: %w", name, classify(err))
+		}
+	}
+
+	hasSnapshot := false
+	if opts.Snapshot != "" {
+		_, _, err = c.server.GetInstanceSnapshot(name, opts.Snapshot)
+		if err != nil && !api.StatusErrorCheck(err, http.StatusNotFound) {
+			return fmt.Errorf("🔍 [RECYCLE] failed to look up snapshot '%s' of VM '%s': %w", opts.Snapshot, name, classify(err))
+		}
+		hasSnapshot = err == nil
+	}
+
+	switch {
+	case hasSnapshot:
+		restore, err := c.server.UpdateInstance(name, api.InstancePut{Restore: opts.Snapshot}, "")
+		if err != nil {
+			return fmt.Errorf("⏪ [RECYCLE] failed to restore VM '%s' from snapshot '%s': %w", name, opts.Snapshot, classify(err))
+		}
+		if err = c.wait(ctx, restore); err != nil {
+			return fmt.Errorf("⏰ [RECYCLE] failed to wait for VM '%s' restore: %w", name, classify(err))
+		}
+	case opts.Source == "":
+		rebuild, err := c.server.RebuildInstance(name, api.InstanceRebuildPost{Source: api.InstanceSource{Type: "image", Alias: opts.Image}})
+		if err != nil {
+			return fmt.Errorf("🔨 [RECYCLE] failed to rebuild VM '%s' from image '%s': %w", name, opts.Image, classify(err))
+		}
+		if err = c.wait(ctx, rebuild); err != nil {
+			return fmt.Errorf("⏰ [RECYCLE] failed to wait for VM '%s' rebuild: %w", name, classify(err))
+		}
+	default:
+		return fmt.Errorf("♻️ [RECYCLE] VM '%s' has no snapshot '%s' and was copied from '%s': %w", name, opts.Snapshot, opts.Source, ErrNotRecyclable)
+	}
+
+	// Back into the pool: fresh ownership, limits and pool tags
+	inst, etag, err := c.server.GetInstance(name)
+	if err != nil {
+		return fmt.Errorf("🔍 [RECYCLE] failed to find VM '%s': %w", name, classify(err))
+	}
+	put := inst.Writable()
+	if put.Config == nil {
+		put.Config = map[string]string{}
+	}
+	for key, value := range opts.config() {
+		put.Config[key] = value
+	}
+	put.Config[ConfigKeyImage] = opts.Image
+	if opts.Source != "" {
+		put.Config[ConfigKeyImage] = opts.Source
+	}
+	update, err := c.server.UpdateInstance(name, put, etag)
+	if err != nil {
+		return fmt.Errorf("⚙️ [RECYCLE] failed to configure VM '%s': %w", name, classify(err))
+	}
+	if err = c.wait(ctx, update); err != nil {
+		return fmt.Errorf("⏰ [RECYCLE] failed to wait for VM '%s' configuration: %w", name, classify(err))
+	}
+
+	rename, err := c.server.RenameInstance(name, api.InstancePost{Name: opts.Name})
+	if err != nil {
+		return fmt.Errorf("🏷️ [RECYCLE] failed to rename VM '%s' to '%s': %w", name, opts.Name, classify(err))
+	}
+	if err = c.wait(ctx, rename); err != nil {
+		return fmt.Errorf("⏰ [RECYCLE] failed to wait for VM '%s' rename: %w", name, classify(err))
+	}
+
+	return nil
+}
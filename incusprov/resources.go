@@ -0,0 +1,100 @@
+package incusprov
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	incus "github.com/lxc/incus/client"
+)
+
+// HostResources is the capacity of the Incus host, as far as admission control cares
+type HostResources struct {
+	CPUs      uint64 // CPU threads
+	Memory    uint64 // Total memory in bytes
+	PoolTotal uint64 // Size of StoragePool in bytes
+	PoolUsed  uint64 // Space used in StoragePool in bytes
+}
+
+// GetImageFingerprint returns the fingerprint of the image an alias currently points to
+func (c *Client) GetImageFingerprint(ctx context.Context, alias string) (string, error) {
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+
+	entry, _, err := c.server.GetImageAlias(alias)
+	if err != nil {
+		return "", fmt.Errorf("🔍 [IMAGE] failed to resolve image alias '%s': %w", alias, classify(err))
+	}
+	return entry.Target, nil
+}
+
+// GetHostResources reads CPU and memory of the server and the usage of StoragePool. With a
+// target, those of the target member are read instead, or the totals of the online members of a
+// "@group" target.
+func (c *Client) GetHostResources(ctx context.Context) (res HostResources, err error) {
+	if err = ctx.Err(); err != nil {
+		return
+	}
+
+	group, inGroup := strings.CutPrefix(c.target, "@")
+	switch {
+	case c.target == "":
+		return hostResources(c.server, "")
+	case !inGroup:
+		return hostResources(c.server.UseTarget(c.target), c.target)
+	}
+
+	members, err := c.server.GetClusterMembers()
+	if err != nil {
+		err = fmt.Errorf("📏 [CAPACITY] failed to list cluster members: %w", classify(err))
+		return
+	}
+
+	found := false
+	for _, m := range members {
+		if m.Status != "Online" || !slices.Contains(m.Groups, group) {
+			continue
+		}
+
+		member, err := hostResources(c.server.UseTarget(m.ServerName), m.ServerName)
+		if err != nil {
+			return HostResources{}, err
+		}
+		res.CPUs += member.CPUs
+		res.Memory += member.Memory
+		res.PoolTotal += member.PoolTotal
+		res.PoolUsed += member.PoolUsed
+		found = true
+	}
+	if !found {
+		err = fmt.Errorf("📏 [CAPACITY] no online member in cluster group '%s': %w", group, ErrNotFound)
+	}
+	return
+}
+
+// hostResources reads the resources of one server, or of the cluster member it targets
+func hostResources(server incus.InstanceServer, member string) (HostResources, error) {
+	where := ""
+	if member != "" {
+		where = fmt.Sprintf(" of cluster member '%s'", member)
+	}
+
+	resources, err := server.GetServerResources()
+	if err != nil {
+		return HostResources{}, fmt.Errorf("📏 [CAPACITY] failed to get server resources%s: %w", where, classify(err))
+	}
+
+	pool, err := server.GetStoragePoolResources(StoragePool)
+	if err != nil {
+		return HostResources{}, fmt.Errorf("📏 [CAPACITY] failed to get usage of storage pool '%s'%s: %w", StoragePool, where, classify(err))
+	}
+
+	return HostResources{
+		CPUs:      resources.CPU.Total,
+		Memory:    resources.Memory.Total,
+		PoolTotal: pool.Space.Total,
+		PoolUsed:  pool.Space.Used,
+	}, nil
+}
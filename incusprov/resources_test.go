@@ -0,0 +1,80 @@
+package incusprov
+
+import (
+	"context"
+	"testing"
+
+	incus "github.com/lxc/incus/client"
+	"github.com/lxc/incus/shared/api"
+)
+
+// clusterServer answers resource requests for a cluster of equal members, each with as many
+// CPU threads as its number in the name. Other methods are not implemented.
+type clusterServer struct {
+	incus.InstanceServer
+	target string
+}
+
+func (s *clusterServer) UseTarget(name string) incus.InstanceServer {
+	return &clusterServer{target: name}
+}
+
+func (s *clusterServer) cpus() uint64 {
+	if s.target == "" {
+		return 1 // The member answering the request
+	}
+	return uint64(s.target[len(s.target)-1] - '0')
+}
+
+func (s *clusterServer) GetServerResources() (*api.Resources, error) {
+	res := &api.Resources{}
+	res.CPU.Total = s.cpus()
+	res.Memory.Total = s.cpus() << 30
+	return res, nil
+}
+
+func (s *clusterServer) GetStoragePoolResources(name string) (*api.ResourcesStoragePool, error) {
+	res := &api.ResourcesStoragePool{}
+	res.Space.Total = s.cpus() << 40
+	res.Space.Used = s.cpus() << 30
+	return res, nil
+}
+
+func (s *clusterServer) GetClusterMembers() ([]api.ClusterMember, error) {
+	member := func(name, status string, groups ...string) api.ClusterMember {
+		return api.ClusterMember{ClusterMemberPut: api.ClusterMemberPut{Groups: groups}, ServerName: name, Status: status}
+	}
+	return []api.ClusterMember{
+		member("node1", "Online", "default"),
+		member("node2", "Online", "default", "runners"),
+		member("node3", "Online", "default", "runners"),
+		member("node4", "Offline", "default", "runners"),
+	}, nil
+}
+
+func TestGetHostResources(t *testing.T) {
+	tests := []struct {
+		target string
+		cpus   uint64
+	}{
+		{"", 1},
+		{"node2", 2},
+		{"@runners", 5}, // Online members only
+	}
+
+	for _, tt := range tests {
+		c := (&Client{server: &clusterServer{}}).UseTarget(tt.target)
+		res, err := c.GetHostResources(context.Background())
+		if err != nil {
+			t.Fatalf("%q: %v", tt.target, err)
+		}
+		want := HostResources{CPUs: tt.cpus, Memory: tt.cpus << 30, PoolTotal: tt.cpus << 40, PoolUsed: tt.cpus << 30}
+		if res != want {
+			t.Errorf("%q: %+v, want %+v", tt.target, res, want)
+		}
+	}
+
+	if _, err := (&Client{server: &clusterServer{}}).UseTarget("@gpu").GetHostResources(context.Background()); err == nil {
+		t.Error("read resources of an empty cluster group")
+	}
+}
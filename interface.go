@@ -9,6 +9,7 @@ import (
 	"path/filepath"
 	"strings"
 	"sync"
+	"time"
 
 	"fleeting-plugin-incus/incusprov"
 
@@ -21,21 +22,82 @@ var _ provider.InstanceGroup = (*InstanceGroup)(nil)
 type InstanceGroup struct {
 	m *sync.Mutex
 
-	IncusImage            string `json:"incus_image"`
-	IncusNamingScheme     string `json:"incus_naming_scheme"`
-	IncusInstanceKeyPath  string `json:"incus_instance_key_path"`
-	IncusInstanceSize     string `json:"incus_instance_size"`
-	IncusDiskSize         string `json:"incus_disk_size"`           // Disk size for VMs (e.g., "100GiB")
-	IncusStartupTimeout   int    `json:"incus_startup_timeout"`     // Timeout in seconds for VM startup
-	IncusOperationTimeout int    `json:"incus_operation_timeout"`   // Timeout in seconds for Incus operations
-	IncusDeleteOnlyOwnVMs bool   `json:"incus_delete_only_own_vms"` // Only delete VMs matching our naming scheme
-	MaxInstances          int    `json:"max_instances"`
-	StateFilePath         string `json:"state_file_path"`
+	IncusImage             string        `json:"incus_image"`
+	IncusNamingScheme      string        `json:"incus_naming_scheme"`
+	IncusInstanceKeyPath   string        `json:"incus_instance_key_path"`
+	IncusInstanceSize      string        `json:"incus_instance_size"`
+	IncusInstanceType      string        `json:"incus_instance_type"`             // "virtual-machine" (default) or "container"
+	IncusDiskSize          string        `json:"incus_disk_size"`                 // Disk size for VMs (e.g., "100GiB")
+	IncusStartupTimeout    int           `json:"incus_startup_timeout"`           // Timeout in seconds for VM startup
+	IncusOperationTimeout  int           `json:"incus_operation_timeout"`         // Timeout in seconds for Incus operations
+	IncusDeleteOnlyOwnVMs  bool          `json:"incus_delete_only_own_vms"`       // Only delete VMs tagged with our group
+	IncusGroup             string        `json:"incus_group"`                     // Ownership tag stamped on created VMs (default: hostname)
+	IncusRemoteURL         string        `json:"incus_remote_url"`                // Remote Incus server (https://host:8443), empty for local socket
+	IncusSocketPath        string        `json:"incus_socket_path"`               // Custom unix socket path (e.g. /run/incus/unix.socket.user)
+	IncusClientCert        string        `json:"incus_client_cert"`               // Client certificate for remote servers
+	IncusClientKey         string        `json:"incus_client_key"`                // Client key for remote servers
+	IncusServerCert        string        `json:"incus_server_cert"`               // Server certificate to pin
+	IncusCACert            string        `json:"incus_ca_cert"`                   // CA certificate that signed the server certificate
+	IncusProject           string        `json:"incus_project"`                   // Incus project all instances live in (default: server default)
+	IncusProjectCreate     bool          `json:"incus_project_create"`            // Create incus_project on Init if missing
+	IncusProjectInstances  int           `json:"incus_project_limit_instances"`   // limits.instances for a created project
+	IncusProjectCPU        int           `json:"incus_project_limit_cpu"`         // limits.cpu for a created project
+	IncusProjectMemory     string        `json:"incus_project_limit_memory"`      // limits.memory for a created project (e.g. "64GiB")
+	IncusProjectDisk       string        `json:"incus_project_limit_disk"`        // limits.disk for a created project (e.g. "1TiB")
+	IncusCloudInitUser     string        `json:"incus_cloud_init_user_data"`      // cloud-init user-data template (inline or file path)
+	IncusCloudInitVendor   string        `json:"incus_cloud_init_vendor_data"`    // cloud-init vendor-data template (inline or file path)
+	IncusCloudInitNetwork  string        `json:"incus_cloud_init_network_config"` // cloud-init network-config template (inline or file path)
+	IncusReadinessProbes   []ProbeConfig `json:"incus_readiness_probes"`          // Probes that must pass before a VM is running
+	IncusFailureRetention  string        `json:"incus_failure_retention"`         // "delete" (default) or "keep" never-ready VMs for debugging
+	IncusCreateConcurrency int           `json:"incus_create_concurrency"`        // Max VMs created in parallel (default: 5)
+	IncusDeleteConcurrency int           `json:"incus_delete_concurrency"`        // Max VMs deleted in parallel (default: 10)
+	IncusBreakerThreshold  int           `json:"incus_circuit_breaker_threshold"` // Consecutive creation failures that pause scale-ups (default: 5)
+	IncusBreakerCooldown   int           `json:"incus_circuit_breaker_cooldown"`  // Seconds scale-ups stay paused (default: 300)
+	IncusAdmissionControl  string        `json:"incus_admission_control"`         // "enforce" (default) host capacity checks on scale-up, or "off"
+	IncusCPUOvercommit     float64       `json:"incus_cpu_overcommit"`            // vCPUs allocatable per host CPU thread (default: 4)
+	IncusMemoryOvercommit  float64       `json:"incus_memory_overcommit"`         // Memory allocatable per byte of host memory (default: 1)
+	IncusPoolMinFree       int           `json:"incus_storage_pool_min_free"`     // Percent of the storage pool kept free (default: 10)
+	IncusWarmPoolSize      int           `json:"incus_warm_pool_size"`            // Stopped instances kept ready for scale-ups (default: 0)
+	IncusStatefulSnapshot  bool          `json:"incus_stateful_snapshot"`         // Restore VMs from a stateful snapshot of a booted template
+	IncusRecycle           string        `json:"incus_recycle"`                   // "off" (default), "snapshot" or "rebuild" instances into the warm pool on scale-down
+	IncusSourceInstance    string        `json:"incus_source_instance"`           // Golden instance to copy instead of unpacking incus_image
+	IncusSourceSnapshot    string        `json:"incus_source_snapshot"`           // Snapshot of incus_source_instance to copy instead of its current state
+	IncusTarget            string        `json:"incus_target"`                    // Cluster member or "@group" to create instances on
+	IncusPlacement         string        `json:"incus_placement"`                 // "off" (default), "spread", "pack" or "least-loaded" cluster member choice
+	MetricsListenAddress   string        `json:"metrics_listen_address"`          // Serve Prometheus metrics on this address (e.g. ":9090")
+	MaxInstances           int           `json:"max_instances"`
+	StateFilePath          string        `json:"state_file_path"`
+
+	// Backend the instances are managed through; Init connects to Incus if nil
+	Backend Backend `json:"-"`
 
 	log      hclog.Logger
 	settings provider.Settings
 
-	status map[string]provider.State
+	status  map[string]provider.State
+	members map[string]string // Cluster member of each instance in status, where known
+
+	cloudInit cloudInitTemplates
+	size      instanceResources
+	publicKey string
+	keys      keyStore
+	metrics   metrics
+
+	// Background provisioning and deletion
+	ctx         context.Context
+	cancel      context.CancelFunc
+	wg          sync.WaitGroup
+	createSlots chan struct{}
+	deleteSlots chan struct{}
+	busy        map[string]bool // Instances owned by a create or delete worker
+	breaker     breaker
+
+	// Warm pool of stopped instances, see runPool
+	pool     []string      // Pooled instances ready to be claimed, not in status
+	poolWake chan struct{} // Asks the refiller to top up the pool
+
+	template  string // Snapshot template VM to clone, once prepared (see runTemplate)
+	recycling int    // Instances on their way back into the pool
 }
 
 func (g *InstanceGroup) Init(ctx context.Context, log hclog.Logger, settings provider.Settings) (provider.ProviderInfo, error) {
@@ -58,9 +120,12 @@ func (g *InstanceGroup) Init(ctx context.Context, log hclog.Logger, settings pro
 
 	// Create state directory and load existing state
 	os.MkdirAll(filepath.Dir(g.StateFilePath), 0755)
-	g.status, _ = load(g.StateFilePath)
+	g.status, g.members, _ = load(g.StateFilePath)
 	g.log.Info("💾 [INIT] State file loaded", "existing_vms", len(g.status))
 
+	// Per-instance SSH keys live next to the state file
+	g.keys = keyStore{dir: filepath.Join(filepath.Dir(g.StateFilePath), "keys")}
+
 	// Set default configuration values
 	if g.IncusNamingScheme == "" {
 		g.IncusNamingScheme = "runner-$random"
@@ -68,10 +133,22 @@ func (g *InstanceGroup) Init(ctx context.Context, log hclog.Logger, settings pro
 	if g.IncusImage == "" {
 		g.IncusImage = "runner-base" // Use local base image by default
 	}
+	if g.IncusGroup == "" {
+		// One runner manager per host by default; set explicitly when sharing a host
+		hostname, err := os.Hostname()
+		if err != nil {
+			return provider.ProviderInfo{}, fmt.Errorf("failed to determine default incus_group: %w", err)
+		}
+		g.IncusGroup = hostname
+	}
 	if g.IncusInstanceSize == "" {
 		g.IncusInstanceSize = "c1-m2" // 1 CPU, 1GB RAM - more descriptive than t2.micro
 	}
-	if g.IncusDiskSize == "" {
+	if g.IncusInstanceType == "" {
+		g.IncusInstanceType = incusprov.TypeVM
+	}
+	// Containers keep the profile's root disk unless a size is configured
+	if g.IncusDiskSize == "" && g.IncusInstanceType == incusprov.TypeVM {
 		g.IncusDiskSize = "10GiB" // Default 100GB root disk
 	}
 	if g.IncusStartupTimeout == 0 {
@@ -87,10 +164,46 @@ func (g *InstanceGroup) Init(ctx context.Context, log hclog.Logger, settings pro
 	if g.MaxInstances == 0 {
 		g.MaxInstances = 20 // More reasonable default
 	}
+	if g.IncusFailureRetention == "" {
+		g.IncusFailureRetention = retentionDelete
+	}
+	if g.IncusCreateConcurrency <= 0 {
+		g.IncusCreateConcurrency = 5
+	}
+	if g.IncusDeleteConcurrency <= 0 {
+		g.IncusDeleteConcurrency = 10
+	}
+	if g.IncusBreakerThreshold <= 0 {
+		g.IncusBreakerThreshold = 5
+	}
+	if g.IncusBreakerCooldown <= 0 {
+		g.IncusBreakerCooldown = 300 // 5 minutes
+	}
+	if g.IncusPlacement == "" {
+		g.IncusPlacement = placementOff
+	}
+	if g.IncusRecycle == "" {
+		g.IncusRecycle = recycleOff
+	}
+	if g.IncusAdmissionControl == "" {
+		g.IncusAdmissionControl = admissionEnforce
+	}
+	if g.IncusCPUOvercommit == 0 {
+		g.IncusCPUOvercommit = 4
+	}
+	if g.IncusMemoryOvercommit == 0 {
+		g.IncusMemoryOvercommit = 1
+	}
+	if g.IncusPoolMinFree == 0 {
+		g.IncusPoolMinFree = 10
+	}
 
 	// Validate configuration
 	if g.IncusInstanceKeyPath == "" {
-		g.log.Warn("⚠️ [INIT] SSH key path not configured", "config_key", "incus_instance_key_path")
+		if g.settings.UseStaticCredentials {
+			g.log.Error("❌ [INIT] SSH key path required for static credentials", "config_key", "incus_instance_key_path")
+			return provider.ProviderInfo{}, fmt.Errorf("incus_instance_key_path is required when use_static_credentials is set")
+		}
 	} else {
 		if _, err := os.Stat(g.IncusInstanceKeyPath); os.IsNotExist(err) {
 			g.log.Error("❌ [INIT] SSH key file not found", "path", g.IncusInstanceKeyPath)
@@ -98,22 +211,199 @@ func (g *InstanceGroup) Init(ctx context.Context, log hclog.Logger, settings pro
 		}
 	}
 
+	if g.IncusRemoteURL != "" {
+		if g.IncusSocketPath != "" {
+			return provider.ProviderInfo{}, fmt.Errorf("incus_remote_url and incus_socket_path are mutually exclusive")
+		}
+		if !strings.HasPrefix(g.IncusRemoteURL, "https://") {
+			return provider.ProviderInfo{}, fmt.Errorf("incus_remote_url must be an https:// URL: %s", g.IncusRemoteURL)
+		}
+		if g.IncusClientCert == "" || g.IncusClientKey == "" {
+			return provider.ProviderInfo{}, fmt.Errorf("incus_remote_url requires incus_client_cert and incus_client_key")
+		}
+		if g.IncusServerCert == "" && g.IncusCACert == "" {
+			g.log.Warn("⚠️ [INIT] No server certificate or CA configured, relying on system trust store", "remote_url", g.IncusRemoteURL)
+		}
+	}
+
+	// Parse cloud-init templates up front so mistakes fail Init, not every creation
+	g.cloudInit, err = parseCloudInit(map[string]string{
+		"cloud-init.user-data":      g.IncusCloudInitUser,
+		"cloud-init.vendor-data":    g.IncusCloudInitVendor,
+		"cloud-init.network-config": g.IncusCloudInitNetwork,
+	})
+	if err != nil {
+		g.log.Error("❌ [INIT] Invalid cloud-init configuration", "error", err)
+		return provider.ProviderInfo{}, err
+	}
+	if g.IncusInstanceKeyPath != "" {
+		g.publicKey, err = publicKeyFromPrivate(g.IncusInstanceKeyPath)
+		if err != nil {
+			g.log.Error("❌ [INIT] Failed to derive SSH public key", "path", g.IncusInstanceKeyPath, "error", err)
+			return provider.ProviderInfo{}, err
+		}
+	}
+
+	if g.IncusFailureRetention != retentionDelete && g.IncusFailureRetention != retentionKeep {
+		return provider.ProviderInfo{}, fmt.Errorf("incus_failure_retention must be %q or %q: %s", retentionDelete, retentionKeep, g.IncusFailureRetention)
+	}
+	if err = validateProbes(g.IncusReadinessProbes); err != nil {
+		g.log.Error("❌ [INIT] Invalid readiness probes", "error", err)
+		return provider.ProviderInfo{}, err
+	}
+
+	if g.IncusInstanceType != incusprov.TypeVM && g.IncusInstanceType != incusprov.TypeContainer {
+		return provider.ProviderInfo{}, fmt.Errorf("incus_instance_type must be %q or %q: %s", incusprov.TypeVM, incusprov.TypeContainer, g.IncusInstanceType)
+	}
+	if g.IncusProjectCreate && g.IncusProject == "" {
+		return provider.ProviderInfo{}, fmt.Errorf("incus_project_create requires incus_project")
+	}
+
+	if g.IncusAdmissionControl != admissionEnforce && g.IncusAdmissionControl != admissionOff {
+		return provider.ProviderInfo{}, fmt.Errorf("incus_admission_control must be %q or %q: %s", admissionEnforce, admissionOff, g.IncusAdmissionControl)
+	}
+	if g.IncusCPUOvercommit < 0 || g.IncusMemoryOvercommit < 0 {
+		return provider.ProviderInfo{}, fmt.Errorf("incus_cpu_overcommit and incus_memory_overcommit must be positive")
+	}
+	if g.IncusSourceSnapshot != "" && g.IncusSourceInstance == "" {
+		return provider.ProviderInfo{}, fmt.Errorf("incus_source_snapshot requires incus_source_instance")
+	}
+	if strings.Contains(g.IncusSourceInstance, "/") || strings.Contains(g.IncusSourceSnapshot, "/") {
+		return provider.ProviderInfo{}, fmt.Errorf("incus_source_instance and incus_source_snapshot take plain names: %s/%s", g.IncusSourceInstance, g.IncusSourceSnapshot)
+	}
+	if g.IncusStatefulSnapshot && g.IncusInstanceType != incusprov.TypeVM {
+		return provider.ProviderInfo{}, fmt.Errorf("incus_stateful_snapshot requires incus_instance_type %q", incusprov.TypeVM)
+	}
+	if g.IncusWarmPoolSize < 0 {
+		return provider.ProviderInfo{}, fmt.Errorf("incus_warm_pool_size must not be negative: %d", g.IncusWarmPoolSize)
+	}
+	switch g.IncusRecycle {
+	case recycleOff:
+	case recycleSnapshot, recycleRebuild:
+		if g.IncusWarmPoolSize == 0 {
+			return provider.ProviderInfo{}, fmt.Errorf("incus_recycle requires incus_warm_pool_size")
+		}
+		if g.IncusRecycle == recycleRebuild && g.IncusSourceInstance != "" {
+			return provider.ProviderInfo{}, fmt.Errorf("incus_recycle %q needs an image, use %q with incus_source_instance", recycleRebuild, recycleSnapshot)
+		}
+	default:
+		return provider.ProviderInfo{}, fmt.Errorf("incus_recycle must be %q, %q or %q: %s", recycleOff, recycleSnapshot, recycleRebuild, g.IncusRecycle)
+	}
+	switch g.IncusPlacement {
+	case placementOff:
+	case placementSpread, placementPack, placementLeastLoaded:
+		if g.IncusTarget != "" && !strings.HasPrefix(g.IncusTarget, "@") {
+			return provider.ProviderInfo{}, fmt.Errorf("incus_placement needs all members or a cluster group (\"@group\") as incus_target: %s", g.IncusTarget)
+		}
+	default:
+		return provider.ProviderInfo{}, fmt.Errorf("incus_placement must be %q, %q, %q or %q: %s", placementOff, placementSpread, placementPack, placementLeastLoaded, g.IncusPlacement)
+	}
+	if g.IncusPoolMinFree < 0 || g.IncusPoolMinFree >= 100 {
+		return provider.ProviderInfo{}, fmt.Errorf("incus_storage_pool_min_free must be a percentage below 100: %d", g.IncusPoolMinFree)
+	}
+
+	// Sizes are resolved here, so typos fail Init instead of every creation
+	g.size.cpus, g.size.memory, g.size.instanceType, err = parseInstanceSize(g.IncusInstanceSize)
+	if err != nil {
+		g.log.Error("❌ [INIT] Invalid instance size", "size", g.IncusInstanceSize, "error", err)
+		return provider.ProviderInfo{}, fmt.Errorf("incus_instance_size: %w", err)
+	}
+	if g.size.instanceType != "" {
+		g.log.Warn("⚠️ [INIT] Instance size left to Incus, admission control cannot account CPU and memory", "size", g.IncusInstanceSize)
+	}
+	g.size.disk, err = parseDiskSize(g.IncusDiskSize)
+	if err != nil {
+		g.log.Error("❌ [INIT] Invalid disk size", "disk_size", g.IncusDiskSize, "error", err)
+		return provider.ProviderInfo{}, fmt.Errorf("incus_disk_size: %w", err)
+	}
+
 	g.log.Info("⚙️ [INIT] Configuration validated",
 		"image", g.IncusImage,
+		"source", g.source(),
+		"type", g.IncusInstanceType,
 		"size", g.IncusInstanceSize,
+		"cpus", g.size.cpus,
+		"memory_mib", g.size.memory>>20,
 		"disk_size", g.IncusDiskSize,
 		"naming_scheme", g.IncusNamingScheme,
+		"group", g.IncusGroup,
 		"max_instances", g.MaxInstances,
+		"create_concurrency", g.IncusCreateConcurrency,
+		"delete_concurrency", g.IncusDeleteConcurrency,
+		"breaker_threshold", g.IncusBreakerThreshold,
+		"breaker_cooldown", g.IncusBreakerCooldown,
+		"admission_control", g.IncusAdmissionControl,
+		"warm_pool_size", g.IncusWarmPoolSize,
+		"recycle", g.IncusRecycle,
+		"target", g.IncusTarget,
+		"placement", g.IncusPlacement,
+		"stateful_snapshot", g.IncusStatefulSnapshot,
 		"startup_timeout", g.IncusStartupTimeout,
 		"operation_timeout", g.IncusOperationTimeout,
-		"ssh_key_path", g.IncusInstanceKeyPath)
+		"ssh_key_path", g.IncusInstanceKeyPath,
+		"ephemeral_keys", !g.settings.UseStaticCredentials,
+		"remote_url", g.IncusRemoteURL,
+		"socket_path", g.IncusSocketPath,
+		"cloud_init_keys", len(g.cloudInit),
+		"readiness_probes", len(g.IncusReadinessProbes),
+		"failure_retention", g.IncusFailureRetention,
+		"metrics_address", g.MetricsListenAddress)
+
+	// Connect to Incus, unless a backend was plugged in
+	if g.Backend == nil {
+		client, err := g.connect(ctx)
+		if err != nil {
+			return provider.ProviderInfo{}, err
+		}
+		g.Backend = client
+	}
 
-	// Connect to Incus
-	g.log.Info("🔌 [INIT] Connecting to Incus daemon")
-	err = incusprov.ConnectIncus()
-	if err != nil {
-		g.log.Error("❌ [INIT] Incus connection failed", "error", err)
-		return provider.ProviderInfo{}, err
+	// Placement by the plugin needs cluster members to choose from
+	if g.IncusPlacement != placementOff {
+		members, err := g.Backend.GetClusterMembers(ctx)
+		if err == nil && len(members) == 0 {
+			err = fmt.Errorf("not a clustered Incus server")
+		}
+		if err != nil {
+			g.log.Error("❌ [INIT] Cluster members unavailable", "placement", g.IncusPlacement, "error", err)
+			return provider.ProviderInfo{}, fmt.Errorf("incus_placement: %w", err)
+		}
+		g.log.Info("🖧 [INIT] Placing instances on cluster members", "placement", g.IncusPlacement, "target", g.IncusTarget, "members", len(members))
+	}
+
+	// A missing golden instance would fail every creation
+	if g.IncusSourceInstance != "" {
+		if _, err := g.Backend.GetInstance(ctx, g.IncusSourceInstance); err != nil {
+			g.log.Error("❌ [INIT] Source instance not found", "source", g.IncusSourceInstance, "error", err)
+			return provider.ProviderInfo{}, fmt.Errorf("incus_source_instance: %w", err)
+		}
+	}
+
+	if g.MetricsListenAddress != "" {
+		if err = g.metrics.serve(g.MetricsListenAddress, g.log); err != nil {
+			g.log.Error("❌ [INIT] Metrics endpoint failed", "address", g.MetricsListenAddress, "error", err)
+			return provider.ProviderInfo{}, err
+		}
+		g.log.Info("📊 [INIT] Serving metrics", "address", g.MetricsListenAddress, "path", "/metrics")
+	}
+
+	// Background workers outlive the Increase call that queued them
+	g.ctx, g.cancel = context.WithCancel(context.Background())
+	g.createSlots = make(chan struct{}, g.IncusCreateConcurrency)
+	g.deleteSlots = make(chan struct{}, g.IncusDeleteConcurrency)
+	g.busy = make(map[string]bool)
+	g.breaker = breaker{
+		threshold: g.IncusBreakerThreshold,
+		coolDown:  time.Duration(g.IncusBreakerCooldown) * time.Second,
+	}
+	g.poolWake = make(chan struct{}, 1)
+	if g.IncusWarmPoolSize > 0 {
+		g.wg.Add(1)
+		go g.runPool()
+	}
+	if g.IncusStatefulSnapshot {
+		g.wg.Add(1)
+		go g.runTemplate()
 	}
 
 	g.log.Info("✅ [INIT] Plugin ready",
@@ -133,18 +423,104 @@ func (g *InstanceGroup) Init(ctx context.Context, log hclog.Logger, settings pro
 // Update updates instance data from the instance group, passing a function
 // to perform instance reconciliation.
 func (g *InstanceGroup) Update(ctx context.Context, update func(id string, state provider.State)) error {
+	// Ask Incus for the real status instead of trusting the state file
+	vms, err := g.Backend.ListInstances(ctx)
+	if err != nil {
+		g.log.Error("❌ [UPDATE] Failed to list instances from Incus", "error", err)
+		return err
+	}
+
 	g.m.Lock()
 	defer g.m.Unlock()
 
+	// Adopt instances tagged with our group that the state file lost track of
+	for name, vm := range vms {
+		// Pooled instances are not reported until Increase claims them, the snapshot template never
+		if _, tracked := g.status[name]; !tracked && vm.Group == g.IncusGroup && !vm.Pool && !g.protected(name) {
+			g.log.Info("🧲 [UPDATE] Adopting VM owned by this group", "vm_name", name, "incus_status", vm.Status)
+			g.status[name] = provider.StateRunning
+		}
+	}
+
 	for id, state := range g.status {
-		update(id, state)
+		vm, exists := vms[id]
+		// An instance re-created under the same name by someone else is not ours
+		exists = exists && g.ownsVM(id, vm.Group)
+
+		// Incus may have chosen the member, or moved the instance (e.g. on evacuation)
+		if exists && vm.Location != "" && g.members[id] != vm.Location {
+			if g.members[id] != "" {
+				g.log.Info("🖧 [UPDATE] VM moved to another cluster member", "vm_name", id, "old_member", g.members[id], "member", vm.Location)
+			}
+			g.members[id] = vm.Location
+		}
+
+		newState := state
+		switch {
+		case g.busy[id] && (state == provider.StateCreating || state == provider.StateDeleting):
+			// The worker reports the outcome itself, once the SSH key is set up or gone as well
+		case state == provider.StateCreating:
+			// No worker behind it (interrupted by a crash or restart); remove what it left behind
+			newState = reconcileState(provider.StateDeleting, vm.Status, exists)
+		default:
+			newState = reconcileState(state, vm.Status, exists)
+		}
+		if newState != state {
+			g.log.Info("🔄 [UPDATE] VM state changed",
+				"vm_name", id,
+				"incus_status", vm.Status,
+				"old_state", state,
+				"new_state", newState)
+			g.status[id] = newState
+		}
+
+		// Deletion gave up or was interrupted by a restart; try again
+		if newState == provider.StateDeleting && !g.busy[id] {
+			g.log.Info("🔁 [UPDATE] Retrying VM deletion", "vm_name", id)
+			g.queueDelete(id)
+		}
+
+		update(id, newState)
 	}
 
-	save(g.StateFilePath, g.status)
+	save(g.StateFilePath, g.status, g.members)
 
 	return nil
 }
 
+// reconcileState maps the Incus status of an instance onto the state we report to fleeting
+func reconcileState(state provider.State, status string, exists bool) provider.State {
+	switch {
+	case state == provider.StateDeleted:
+		return state
+	case state == provider.StateCreating:
+		// Creation is still in progress, its worker decides the outcome
+		return state
+	case !exists:
+		return provider.StateDeleted
+	case state == provider.StateTimeout:
+		// Never became ready; stays timed out until removed, even if it runs
+		return state
+	}
+
+	switch status {
+	case "Running":
+		if state == provider.StateDeleting {
+			return state
+		}
+		return provider.StateRunning
+	case "Stopped", "Error", "Frozen":
+		// Crashed, stopped or frozen by an operator: no longer usable for jobs
+		if state == provider.StateDeleting {
+			return state
+		}
+		return provider.StateTimeout
+	}
+
+	// Transitional statuses (Starting, Stopping, ...) keep the last known state
+	return state
+}
+
 // ConnectInfo returns additional information about an instance,
 // useful for creating a connection.
 func (g *InstanceGroup) ConnectInfo(ctx context.Context, name string) (provider.ConnectInfo, error) {
@@ -153,25 +529,51 @@ func (g *InstanceGroup) ConnectInfo(ctx context.Context, name string) (provider.
 	g.m.Lock()
 	info := provider.ConnectInfo{ConnectorConfig: g.settings.ConnectorConfig}
 	keyPath := g.IncusInstanceKeyPath
+	if !info.UseStaticCredentials {
+		// Each instance has its own key pair, generated in Increase
+		keyPath = g.keys.path(name)
+	}
 	g.m.Unlock()
 
 	g.log.Debug("📋 [CONNECT] Gathering VM network information", "vm_name", name)
-	ip, err := incusprov.GetVM(name)
+	vm, err := g.Backend.GetInstance(ctx, name)
+	if err == nil && vm.Address == "" {
+		err = fmt.Errorf("🌐 [CONNECT] no suitable IP address found for VM '%s' (status: %s)", name, vm.Status)
+	}
 	if err != nil {
 		g.log.Error("❌ [CONNECT] Failed to get VM network information", "vm_name", name, "error", err)
 		return provider.ConnectInfo{}, err
 	}
+	ip := vm.Address
 
-	g.log.Debug("🔑 [CONNECT] Loading SSH key", "key_path", keyPath)
-	info.OS = "linux"
-	info.Arch = "amd64"
-	info.Protocol = provider.ProtocolSSH
-	info.UseStaticCredentials = true
-	info.Username = "root"
-	info.Key, err = os.ReadFile(keyPath)
-	if err != nil {
-		g.log.Error("❌ [CONNECT] Failed to read SSH key", "key_path", keyPath, "error", err)
-		return provider.ConnectInfo{}, err
+	// Explicit connector_config values win; otherwise derive them from the instance
+	if info.OS == "" {
+		info.OS = osFromImage(vm.ImageOS)
+	}
+	if info.Arch == "" {
+		info.Arch = archFromIncus(vm.Architecture)
+	}
+	if info.Protocol == "" {
+		info.Protocol = provider.ProtocolSSH
+	}
+	if info.Username == "" {
+		info.Username = defaultUsername
+	}
+
+	// A key from connector_config is used as-is
+	if len(info.Key) == 0 {
+		g.log.Debug("🔑 [CONNECT] Loading SSH key", "key_path", keyPath)
+		info.Key, err = os.ReadFile(keyPath)
+		if os.IsNotExist(err) && !info.UseStaticCredentials && g.IncusInstanceKeyPath != "" {
+			// Instances created before per-instance keys became the default have none
+			g.log.Info("🔑 [CONNECT] No per-instance SSH key, using incus_instance_key_path", "vm_name", name, "key_path", g.IncusInstanceKeyPath)
+			keyPath = g.IncusInstanceKeyPath
+			info.Key, err = os.ReadFile(keyPath)
+		}
+		if err != nil {
+			g.log.Error("❌ [CONNECT] Failed to read SSH key", "key_path", keyPath, "error", err)
+			return provider.ConnectInfo{}, err
+		}
 	}
 
 	info.InternalAddr = ip
@@ -180,19 +582,147 @@ func (g *InstanceGroup) ConnectInfo(ctx context.Context, name string) (provider.
 	g.log.Info("✅ [CONNECT] Connection info ready",
 		"vm_name", name,
 		"ip_address", ip,
-		"protocol", "SSH",
-		"username", "root")
+		"os", info.OS,
+		"arch", info.Arch,
+		"protocol", info.Protocol,
+		"username", info.Username)
 
 	return info, nil
 }
 
-// matchesNamingScheme checks if a VM name matches our naming scheme (excluding $random)
-func (g *InstanceGroup) matchesNamingScheme(vmName string) bool {
-	// Remove $random from the naming scheme to get the prefix
-	schemePrefix := strings.ReplaceAll(g.IncusNamingScheme, "$random", "")
+// defaultUsername is used when connector_config sets no username
+const defaultUsername = "root"
+
+// osFromImage maps an image's image.os property (e.g. "Ubuntu", "Windows") to a fleeting OS name
+func osFromImage(imageOS string) string {
+	if strings.Contains(strings.ToLower(imageOS), "windows") {
+		return "windows"
+	}
+	return "linux"
+}
+
+// archFromIncus maps an Incus architecture name to its Go architecture name
+func archFromIncus(arch string) string {
+	switch arch {
+	case "aarch64":
+		return "arm64"
+	case "armv7l", "armv6l":
+		return "arm"
+	case "i686":
+		return "386"
+	case "ppc64le", "s390x", "riscv64":
+		return arch
+	}
+	return "amd64"
+}
+
+// authorizedKeysPath returns the authorized_keys file of the user fleeting connects as
+func (g *InstanceGroup) authorizedKeysPath() string {
+	username := g.settings.Username
+	if username == "" {
+		username = defaultUsername
+	}
+	if username == "root" {
+		return "/root/.ssh/authorized_keys"
+	}
+	return "/home/" + username + "/.ssh/authorized_keys"
+}
+
+// Failure retention policies for instances that time out during startup
+const (
+	retentionDelete = "delete" // Remove the instance from Incus
+	retentionKeep   = "keep"   // Keep it in Incus for debugging
+)
+
+// handleStartupTimeout reports a never-ready instance as timed out and applies the retention policy
+func (g *InstanceGroup) handleStartupTimeout(name string) {
+	g.metrics.timedOut.Add(1)
+
+	g.m.Lock()
+	g.status[name] = provider.StateTimeout
+	save(g.StateFilePath, g.status, g.members)
+	g.m.Unlock()
+
+	if g.IncusFailureRetention == retentionKeep {
+		g.log.Warn("🔍 [CREATE] Keeping timed out VM for inspection", "vm_name", name)
+		return
+	}
+
+	g.log.Info("🗑️ [CREATE] Deleting timed out VM", "vm_name", name)
+	if err := g.Backend.DeleteInstance(g.ctx, name); err != nil {
+		// Update keeps reporting it as timed out; stale cleanup drops it once gone
+		g.log.Error("❌ [CREATE] Failed to delete timed out VM", "vm_name", name, "error", err)
+		return
+	}
+	g.discardKey(name)
+	g.metrics.deleted.Add(1)
+}
+
+// discardKey removes an instance's private key once the instance is gone
+func (g *InstanceGroup) discardKey(name string) {
+	if err := g.keys.remove(name); err != nil {
+		g.log.Warn("⚠️ [DELETE] Failed to remove SSH key", "vm_name", name, "error", err)
+	}
+}
+
+// protected reports whether an instance must never be deleted or reported to fleeting: the golden
+// source instance, the snapshot template (managed on its own) and "runner-base" of older setups
+func (g *InstanceGroup) protected(name string) bool {
+	return name == "runner-base" || name == g.IncusSourceInstance || name == g.templateName()
+}
+
+// source returns the copy source new instances are created from, or "" for incus_image
+func (g *InstanceGroup) source() string {
+	if g.IncusSourceSnapshot != "" {
+		return g.IncusSourceInstance + "/" + g.IncusSourceSnapshot
+	}
+	return g.IncusSourceInstance
+}
+
+// origin returns what new instances are created from, as recorded in incusprov.ConfigKeyImage
+func (g *InstanceGroup) origin() string {
+	if source := g.source(); source != "" {
+		return source
+	}
+	return g.IncusImage
+}
+
+// imageFingerprint returns the fingerprint incus_image currently points to, or "" if instances are
+// copied from incus_source_instance or the alias cannot be resolved
+func (g *InstanceGroup) imageFingerprint(ctx context.Context) string {
+	if g.source() != "" {
+		return ""
+	}
 
-	// VM name should start with this prefix
-	return strings.HasPrefix(vmName, schemePrefix)
+	fingerprint, err := g.Backend.GetImageFingerprint(ctx, g.IncusImage)
+	if err != nil {
+		if ctx.Err() == nil {
+			g.log.Warn("⚠️ [IMAGE] Failed to resolve image alias, re-published images go unnoticed", "image", g.IncusImage, "error", err, "error_class", errorClass(err))
+		}
+		return ""
+	}
+	return fingerprint
+}
+
+// outdated reports whether an instance kept for later (pooled or the snapshot template) was created
+// from something else than new instances are: another image or source, or an older image published
+// under the same alias. fingerprint is the result of imageFingerprint.
+func (g *InstanceGroup) outdated(vm incusprov.VMInfo, fingerprint string) bool {
+	return vm.Image != g.origin() || (fingerprint != "" && vm.BaseImage != fingerprint)
+}
+
+// ownsVM checks if an instance belongs to this group based on its ownership tag.
+// Untagged instances (created by older plugin versions) count as ours only if we track them.
+func (g *InstanceGroup) ownsVM(name, group string) bool {
+	if g.protected(name) {
+		return false
+	}
+	if group != "" {
+		return group == g.IncusGroup
+	}
+
+	_, tracked := g.status[name]
+	return tracked
 }
 
 // Decrease removes the specified instances from the instance group. It
@@ -200,81 +730,76 @@ func (g *InstanceGroup) matchesNamingScheme(vmName string) bool {
 func (g *InstanceGroup) Decrease(ctx context.Context, instances []string) (removed []string, err error) {
 	g.log.Info("📉 [DELETE] Scale down request", "vms_to_delete", len(instances), "vm_names", instances)
 
+	// One listing for the whole batch; deletion itself runs in the background
+	vms, err := g.Backend.ListInstances(ctx)
+	if err != nil {
+		g.log.Error("❌ [DELETE] Failed to list instances from Incus", "error", err)
+		return nil, err
+	}
+
+	g.m.Lock()
+	defer g.m.Unlock()
+
 	for i, name := range instances {
-		// Skip runner-base
-		if name == "runner-base" {
+		if g.protected(name) {
+			g.log.Warn("🛡️ [DELETE] Skipping protected VM", "vm_name", name)
 			continue
 		}
-		// Safety check: Only delete VMs that match our naming scheme if enabled
-		if g.IncusDeleteOnlyOwnVMs && !g.matchesNamingScheme(name) {
-			g.log.Warn("🛡️ [DELETE] Skipping VM - doesn't match naming scheme",
-				"vm_name", name,
-				"naming_scheme", g.IncusNamingScheme,
-				"safety_enabled", g.IncusDeleteOnlyOwnVMs)
-			continue
-		}
-		vmNumber := i + 1
 		g.log.Info("🗑️ [DELETE] Processing VM",
 			"vm_name", name,
-			"progress", fmt.Sprintf("%d/%d", vmNumber, len(instances)))
+			"progress", fmt.Sprintf("%d/%d", i+1, len(instances)))
 
-		// First check if VM exists in Incus
-		_, vmErr := incusprov.GetVM(name)
-		if vmErr != nil {
+		vm, exists := vms[name]
+		if !exists && g.status[name] != provider.StateCreating {
 			g.log.Info("👻 [DELETE] VM not found in Incus (already deleted)", "vm_name", name)
 
 			// VM doesn't exist, just mark as deleted in our state
-			g.m.Lock()
 			g.status[name] = provider.StateDeleted
-			save(g.StateFilePath, g.status)
-			g.m.Unlock()
+			g.discardKey(name)
 
 			removed = append(removed, name)
 			continue
 		}
 
-		// VM exists, try to delete it
-		g.log.Info("⏹️ [DELETE] Stopping and deleting VM", "vm_name", name)
-		deleteErr := incusprov.DeleteVM(name)
-		if deleteErr != nil {
-			g.log.Error("❌ [DELETE] VM deletion failed",
+		// Safety check: Only delete VMs tagged with our group if enabled
+		if exists && g.IncusDeleteOnlyOwnVMs && !g.ownsVM(name, vm.Group) {
+			g.log.Warn("🛡️ [DELETE] Skipping VM - not owned by this group",
 				"vm_name", name,
-				"progress", fmt.Sprintf("%d/%d", vmNumber, len(instances)),
-				"error", deleteErr)
+				"vm_group", vm.Group,
+				"group", g.IncusGroup,
+				"safety_enabled", g.IncusDeleteOnlyOwnVMs)
 			continue
 		}
 
-		// Mark as deleted in state
-		g.m.Lock()
-		g.status[name] = provider.StateDeleted
-		save(g.StateFilePath, g.status)
-		g.m.Unlock()
-
-		g.log.Info("✅ [DELETE] VM deletion completed",
-			"vm_name", name,
-			"progress", fmt.Sprintf("%d/%d", vmNumber, len(instances)))
-
+		// Healthy instances go back into the warm pool; instances still being created are
+		// deleted by their creation worker
+		if g.IncusRecycle != recycleOff && g.status[name] == provider.StateRunning && !g.busy[name] {
+			g.queueRecycle(name)
+		} else {
+			g.queueDelete(name)
+		}
 		removed = append(removed, name)
 	}
+	save(g.StateFilePath, g.status, g.members)
 
 	// Final result logging
 	if len(removed) == len(instances) {
-		g.log.Info("✅ [DELETE] Scale down completed",
+		g.log.Info("✅ [DELETE] Scale down accepted",
 			"requested", len(instances),
-			"deleted", len(removed))
+			"deleting", len(removed),
+			"concurrency", g.IncusDeleteConcurrency)
 	} else if len(removed) > 0 {
 		g.log.Warn("⚠️ [DELETE] Partial scale down",
 			"requested", len(instances),
-			"deleted", len(removed),
-			"failed", len(instances)-len(removed))
+			"deleting", len(removed),
+			"skipped", len(instances)-len(removed))
 	} else {
 		g.log.Error("❌ [DELETE] Scale down failed",
 			"requested", len(instances),
-			"deleted", 0)
+			"deleting", 0)
 	}
 
-	err = nil
-	return
+	return removed, nil
 }
 
 // Increase requests more instances to be created. It returns how many
@@ -282,151 +807,246 @@ func (g *InstanceGroup) Decrease(ctx context.Context, instances []string) (remov
 func (g *InstanceGroup) Increase(ctx context.Context, delta int) (success int, err error) {
 	g.log.Info("📈 [CREATE] Scale up request", "vms_to_create", delta)
 
-	g.m.Lock()
-	originalDelta := delta
-	creatingCount := 0
+	// Stop hammering Incus while creations keep failing the same way
+	trial := false
+	switch g.breaker.state(time.Now()) {
+	case breakerOpen:
+		g.log.Warn("⛔ [BREAKER] Scale up refused, creation circuit is open",
+			"requested", delta,
+			"retry_after", g.breaker.retryAfter(time.Now()))
+		return 0, nil
+	case breakerHalfOpen:
+		g.log.Info("🔁 [BREAKER] Cool-down over, creating one trial VM", "requested", delta)
+		delta = 1
+		trial = true
+	}
 
-	// Count existing VMs in creating state
-	for id := range g.status {
-		if g.status[id] == provider.StateCreating {
-			g.log.Debug("⏳ [ANALYSIS] Found VM in creating state", "vm_name", id)
-			delta--
-			creatingCount++
+	// The ssh probe needs the static key when no per-instance keys are generated
+	ephemeralKeys := !g.settings.UseStaticCredentials
+	var staticKey []byte
+	if !ephemeralKeys && g.IncusInstanceKeyPath != "" {
+		staticKey, err = os.ReadFile(g.IncusInstanceKeyPath)
+		if err != nil {
+			g.log.Error("❌ [CREATE] Failed to read SSH key", "key_path", g.IncusInstanceKeyPath, "error", err)
+			return 0, err
 		}
 	}
 
-	// Log current state analysis
+	// Drop VMs that no longer exist in Incus first, so a drifted state file does not eat capacity
+	// (VMs still being created or deleted are left to their workers)
+	g.m.Lock()
 	totalVMs := len(g.status)
-	g.log.Info("📊 [ANALYSIS] State analysis",
-		"total_vms", totalVMs,
-		"vms_creating", creatingCount,
-		"requested", originalDelta,
-		"will_create", delta)
-
-	namingScheme := g.IncusNamingScheme
-	instanceSize := g.IncusInstanceSize
-	instanceImage := g.IncusImage
-	startupTimeout := g.IncusStartupTimeout
 	g.m.Unlock()
-
-	// Clean up ALL stale VMs that don't exist in Incus (not just StateCreating)
 	if totalVMs > 0 {
 		g.log.Info("🧹 [CLEANUP] Checking for stale VMs", "vms_to_check", totalVMs)
-		cleaned := g.cleanupAllStaleVMs()
-		if cleaned > 0 {
-			// Recalculate counts after cleanup
-			g.m.Lock()
-			newTotal := len(g.status)
-			newCreating := 0
-			for id := range g.status {
-				if g.status[id] == provider.StateCreating {
-					newCreating++
-				}
-			}
-			g.m.Unlock()
+		if cleaned := g.cleanupAllStaleVMs(ctx); cleaned > 0 {
+			g.log.Info("♻️ [CLEANUP] Cleanup completed", "stale_vms_removed", cleaned)
+		}
+	}
 
-			delta = originalDelta - newCreating
-			g.log.Info("♻️ [CLEANUP] Cleanup completed",
-				"stale_vms_removed", cleaned,
-				"new_total", newTotal,
-				"new_creating", newCreating,
-				"new_delta", delta)
+	// Ask the host for its capacity before taking the lock, Incus may be slow to answer
+	var host *incusprov.HostResources
+	if g.IncusAdmissionControl == admissionEnforce {
+		res, err := g.Backend.GetHostResources(ctx)
+		if err != nil {
+			g.log.Warn("⚠️ [CAPACITY] Failed to read host resources, skipping admission control", "error", err, "error_class", errorClass(err))
+		} else {
+			host = &res
 		}
 	}
 
-	// Early exit if no VMs to create
-	if delta <= 0 {
-		g.log.Info("⏸️ [ANALYSIS] No new VMs needed", "reason", "sufficient_existing_or_creating")
-		return 0, nil
+	// Names taken in Incus, by this group or anyone else in the project. Without the listing, a
+	// clash still fails the creation of that instance.
+	vms, listErr := g.Backend.ListInstances(ctx)
+	if listErr != nil {
+		g.log.Warn("⚠️ [CREATE] Failed to list instances, checking names against the state only", "error", listErr, "error_class", errorClass(listErr))
+		vms = nil
 	}
 
-	var lastErr error
-	g.log.Info("🏗️ [CREATE] Starting VM creation", "vms_to_create", delta)
+	// Cluster members and their load are read outside the lock as well
+	plan := g.planPlacement(ctx, vms)
+	if host != nil && plan != nil {
+		// The answering member alone would cap the whole cluster at its own size
+		res := plan.capacity(*host)
+		host = &res
+	}
 
-	for i := range delta {
-		vmNumber := i + 1
-		name := os.Expand(namingScheme, naming)
+	// Count and register under one lock, so concurrent scale ups cannot overshoot max_instances
+	g.m.Lock()
+	defer g.m.Unlock()
 
-		g.log.Info("🔨 [CREATE] Creating VM",
-			"vm_name", name,
-			"progress", fmt.Sprintf("%d/%d", vmNumber, delta),
-			"image", instanceImage,
-			"size", instanceSize,
-			"disk_size", g.IncusDiskSize)
+	// delta is on top of what fleeting already knows about, including VMs still being created.
+	// Everything not on its way out counts against max_instances.
+	requested := delta
+	reason := "max_instances_reached"
+	active, creating, allocated := 0, 0, 0
+	for _, state := range g.status {
+		switch state {
+		case provider.StateDeleted:
+			continue
+		case provider.StateDeleting:
+			// Still holds host resources until Incus has deleted it
+			allocated++
+			continue
+		case provider.StateCreating:
+			creating++
+		}
+		active++
+		allocated++
+	}
+	if available := max(g.MaxInstances-active, 0); delta > available {
+		g.log.Warn("🚫 [CAPACITY] Scale up capped at max_instances",
+			"requested", requested,
+			"active_vms", active,
+			"max_instances", g.MaxInstances,
+			"refused", delta-available)
+		delta = available
+	}
+	if host != nil {
+		if fits, limit := g.admit(*host, allocated); delta > fits {
+			g.log.Warn("🚫 [CAPACITY] Scale up capped by host capacity",
+				"requested", requested,
+				"allocated_vms", allocated,
+				"limited_by", limit,
+				"host_cpus", host.CPUs,
+				"host_memory", host.Memory,
+				"pool_total", host.PoolTotal,
+				"pool_used", host.PoolUsed,
+				"refused", delta-fits)
+			delta = fits
+			reason = "host_capacity_reached"
+		}
+	}
 
-		// Mark VM as creating
-		g.m.Lock()
-		g.status[name] = provider.StateCreating
-		save(g.StateFilePath, g.status)
-		g.m.Unlock()
+	g.log.Info("📊 [ANALYSIS] State analysis",
+		"total_vms", len(g.status),
+		"active_vms", active,
+		"vms_creating", creating,
+		"requested", requested,
+		"will_create", delta)
 
-		// Create the VM
-		createErr := incusprov.CreateVMWithDiskSize(name, instanceSize, instanceImage, startupTimeout, g.IncusDiskSize)
-		if createErr != nil {
-			g.log.Error("❌ [CREATE] VM creation failed",
-				"vm_name", name,
-				"progress", fmt.Sprintf("%d/%d", vmNumber, delta),
-				"error", createErr)
+	// Early exit if no VMs to create
+	if delta <= 0 {
+		g.log.Info("⏸️ [ANALYSIS] No new VMs created", "reason", reason)
+		return 0, nil
+	}
 
-			// Clean up failed VM from state
-			g.m.Lock()
-			delete(g.status, name)
-			save(g.StateFilePath, g.status)
-			g.m.Unlock()
+	g.log.Info("🏗️ [CREATE] Queueing VM creation", "vms_to_create", delta, "concurrency", g.IncusCreateConcurrency)
 
-			lastErr = createErr
+	username := g.settings.Username
+	if username == "" {
+		username = defaultUsername
+	}
+	owner := incusprov.Owner{Group: g.IncusGroup, PluginVersion: Version}
+
+	// Register all instances up front; Update reports their progress.
+	// Instances from the warm pool come first.
+	names := make([]string, 0, delta)
+	claimed := map[string]bool{}
+	for len(names) < delta {
+		name, ok := g.claimPooled()
+		if !ok {
+			break
+		}
+		g.status[name] = provider.StateCreating
+		g.busy[name] = true
+		claimed[name] = true
+		names = append(names, name)
+	}
+	if len(claimed) > 0 {
+		g.wakePool()
+	}
+	for attempts := 0; len(names) < delta && attempts < 10*delta; attempts++ {
+		name := os.Expand(g.IncusNamingScheme, naming)
+		_, exists := vms[name]
+		if _, taken := g.status[name]; taken || exists || g.pooled(name) || g.protected(name) {
 			continue
 		}
+		g.status[name] = provider.StateCreating
+		g.busy[name] = true
+		names = append(names, name)
 
-		// Mark VM as running
-		g.m.Lock()
-		g.status[name] = provider.StateRunning
-		save(g.StateFilePath, g.status)
-		g.m.Unlock()
-
-		g.log.Info("✅ [CREATE] VM creation completed",
-			"vm_name", name,
-			"progress", fmt.Sprintf("%d/%d", vmNumber, delta))
-
-		success++
+		// Pooled instances above stay on the member they were created on
+		member := plan.pick()
+		if member == "" && !strings.HasPrefix(g.IncusTarget, "@") {
+			member = g.IncusTarget
+		}
+		if member != "" {
+			g.members[name] = member
+		}
 	}
-
-	// Final result logging
-	if success == 0 && lastErr != nil {
-		err = lastErr
-		g.log.Error("❌ [CREATE] Scale up failed",
-			"requested", originalDelta,
-			"attempted", delta,
-			"successful", success,
-			"error", err)
-	} else if success < delta {
-		g.log.Warn("⚠️ [CREATE] Partial scale up",
-			"requested", originalDelta,
-			"attempted", delta,
-			"successful", success,
-			"failed", delta-success)
-	} else {
-		g.log.Info("✅ [CREATE] Scale up completed",
-			"requested", originalDelta,
-			"created", success)
+	if len(names) < delta {
+		g.log.Warn("⚠️ [CREATE] Naming scheme ran out of unique names", "naming_scheme", g.IncusNamingScheme, "refused", delta-len(names))
+		delta = len(names)
+		if delta == 0 {
+			return 0, nil
+		}
+	}
+	if trial {
+		g.breaker.startTrial(names[0])
+	}
+	save(g.StateFilePath, g.status, g.members)
+
+	for i, name := range names {
+		g.wg.Add(1)
+		go g.provision(provisionRequest{
+			name:           name,
+			progress:       fmt.Sprintf("%d/%d", i+1, delta),
+			image:          g.IncusImage,
+			source:         g.source(),
+			instanceType:   g.IncusInstanceType,
+			size:           g.IncusInstanceSize,
+			cpus:           g.size.cpus,
+			memory:         g.size.memory,
+			sizeType:       g.size.instanceType,
+			diskSize:       g.IncusDiskSize,
+			startupTimeout: g.IncusStartupTimeout,
+			owner:          owner,
+			ephemeralKeys:  ephemeralKeys,
+			username:       username,
+			staticKey:      staticKey,
+			pooled:         claimed[name],
+			template:       g.template,
+			member:         g.members[name],
+		})
 	}
 
-	return
+	g.log.Info("✅ [CREATE] Scale up accepted",
+		"requested", requested,
+		"queued", delta,
+		"from_warm_pool", len(claimed),
+		"vm_names", names)
+
+	return delta, nil
 }
 
 // Shutdown performs any cleanup tasks required when the plugin is to shutdown.
 func (g *InstanceGroup) Shutdown(ctx context.Context) error {
+	// Stop queued creations and deletions and wait for running ones
+	g.cancel()
+	done := make(chan struct{})
+	go func() {
+		g.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-ctx.Done():
+		g.log.Warn("⚠️ [SHUTDOWN] Gave up waiting for background VM creation and deletion", "error", ctx.Err())
+	}
+
 	g.m.Lock()
 	defer g.m.Unlock()
 
 	for id, state := range g.status {
 		if state == provider.StateDeleted {
 			delete(g.status, id)
+			delete(g.members, id)
 		}
 	}
-	save(g.StateFilePath, g.status)
+	save(g.StateFilePath, g.status, g.members)
 
-	return nil
+	return g.metrics.shutdown(ctx)
 }
 
 func naming(i string) string {
@@ -446,20 +1066,44 @@ func randomString(n int) string {
 	return string(b)
 }
 
-func load(path string) (state map[string]provider.State, err error) {
+// stateFile is the layout of the state file. Older versions wrote the instances map alone,
+// which load still reads.
+type stateFile struct {
+	Instances map[string]provider.State `json:"instances"`
+	Members   map[string]string         `json:"members,omitempty"` // Cluster member of each instance, where known
+}
+
+func load(path string) (state map[string]provider.State, members map[string]string, err error) {
 	state = make(map[string]provider.State)
+	members = make(map[string]string)
 
 	data, err := os.ReadFile(path)
 	if err != nil {
 		return
 	}
 
+	var file stateFile
+	if json.Unmarshal(data, &file) == nil && file.Instances != nil {
+		state = file.Instances
+		if file.Members != nil {
+			members = file.Members
+		}
+		return
+	}
+
 	json.Unmarshal(data, &state)
 	return
 }
 
-func save(path string, state map[string]provider.State) (err error) {
-	data, err := json.MarshalIndent(state, "", "  ")
+func save(path string, state map[string]provider.State, members map[string]string) (err error) {
+	file := stateFile{Instances: state, Members: map[string]string{}}
+	for name, member := range members {
+		if _, tracked := state[name]; tracked {
+			file.Members[name] = member
+		}
+	}
+
+	data, err := json.MarshalIndent(file, "", "  ")
 	if err != nil {
 		return
 	}
@@ -469,7 +1113,13 @@ func save(path string, state map[string]provider.State) (err error) {
 }
 
 // cleanupStaleCreatingVMs removes VMs that are marked as StateCreating but don't exist in Incus
-func (g *InstanceGroup) cleanupStaleCreatingVMs() int {
+func (g *InstanceGroup) cleanupStaleCreatingVMs(ctx context.Context) int {
+	vms, err := g.Backend.ListInstances(ctx)
+	if err != nil {
+		g.log.Error("❌ [CLEANUP] Failed to list instances from Incus", "error", err)
+		return 0
+	}
+
 	g.m.Lock()
 	defer g.m.Unlock()
 
@@ -479,9 +1129,9 @@ func (g *InstanceGroup) cleanupStaleCreatingVMs() int {
 	for id, state := range g.status {
 		if state == provider.StateCreating {
 			g.log.Debug("⏳ [CLEANUP] Checking VM state", "vm_name", id)
-			// Check if VM actually exists in Incus
-			if !incusprov.VMExists(id) {
-				g.log.Warn("👻 [CLEANUP] Found stale creating VM", "vm_name", id, "reason", "not_found_in_incus")
+			// Check if VM actually exists in Incus and is still ours
+			if vm, exists := vms[id]; !exists || !g.ownsVM(id, vm.Group) {
+				g.log.Warn("👻 [CLEANUP] Found stale creating VM", "vm_name", id, "reason", "not_found_or_not_owned")
 				toCleanup = append(toCleanup, id)
 			} else {
 				g.log.Debug("✓ [CLEANUP] Creating VM exists in Incus", "vm_name", id)
@@ -494,9 +1144,10 @@ func (g *InstanceGroup) cleanupStaleCreatingVMs() int {
 		g.log.Info("🧹 [CLEANUP] Cleaning up stale creating VMs", "vms_to_cleanup", len(toCleanup), "vm_names", toCleanup)
 		for _, id := range toCleanup {
 			delete(g.status, id)
+			delete(g.members, id)
 			g.log.Debug("🗑️ [CLEANUP] Removed creating VM from state", "vm_name", id)
 		}
-		save(g.StateFilePath, g.status)
+		save(g.StateFilePath, g.status, g.members)
 	} else {
 		g.log.Debug("✓ [CLEANUP] No stale creating VMs found")
 	}
@@ -504,8 +1155,15 @@ func (g *InstanceGroup) cleanupStaleCreatingVMs() int {
 	return len(toCleanup)
 }
 
-// cleanupAllStaleVMs removes ALL VMs from state that don't exist in Incus anymore
-func (g *InstanceGroup) cleanupAllStaleVMs() int {
+// cleanupAllStaleVMs removes ALL VMs from state that don't exist in Incus anymore (or no longer carry our group tag),
+// except those a background worker is still creating or deleting
+func (g *InstanceGroup) cleanupAllStaleVMs(ctx context.Context) int {
+	vms, err := g.Backend.ListInstances(ctx)
+	if err != nil {
+		g.log.Error("❌ [CLEANUP] Failed to list instances from Incus", "error", err)
+		return 0
+	}
+
 	g.m.Lock()
 	defer g.m.Unlock()
 
@@ -513,11 +1171,16 @@ func (g *InstanceGroup) cleanupAllStaleVMs() int {
 
 	g.log.Debug("🔍 [CLEANUP] Scanning ALL VMs for stale entries")
 	for id, state := range g.status {
+		// Creation or deletion may still be running in the background; its worker settles the state
+		if g.busy[id] {
+			continue
+		}
+
 		g.log.Debug("🔍 [CLEANUP] Checking VM", "vm_name", id, "state", state)
 
-		// Check if VM actually exists in Incus
-		if !incusprov.VMExists(id) {
-			g.log.Warn("👻 [CLEANUP] Found stale VM", "vm_name", id, "state", state, "reason", "not_found_in_incus")
+		// Check if VM actually exists in Incus and is still ours
+		if vm, exists := vms[id]; !exists || !g.ownsVM(id, vm.Group) {
+			g.log.Warn("👻 [CLEANUP] Found stale VM", "vm_name", id, "state", state, "reason", "not_found_or_not_owned")
 			toCleanup = append(toCleanup, id)
 		} else {
 			g.log.Debug("✓ [CLEANUP] VM exists in Incus", "vm_name", id, "state", state)
@@ -530,9 +1193,10 @@ func (g *InstanceGroup) cleanupAllStaleVMs() int {
 		for _, id := range toCleanup {
 			oldState := g.status[id]
 			delete(g.status, id)
+			delete(g.members, id)
 			g.log.Debug("🗑️ [CLEANUP] Removed VM from state", "vm_name", id, "old_state", oldState)
 		}
-		save(g.StateFilePath, g.status)
+		save(g.StateFilePath, g.status, g.members)
 	} else {
 		g.log.Debug("✓ [CLEANUP] No stale VMs found")
 	}
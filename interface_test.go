@@ -0,0 +1,868 @@
+package fleetingincus
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"fleeting-plugin-incus/incusprov"
+
+	"github.com/hashicorp/go-hclog"
+	"gitlab.com/gitlab-org/fleeting/fleeting/provider"
+)
+
+const testGroup = "test-group"
+
+func init() {
+	probeInterval = 10 * time.Millisecond
+	deleteRetryDelay = 10 * time.Millisecond
+	createRetryDelay = 10 * time.Millisecond
+}
+
+// newTestGroup initializes an instance group against the fake backend, with its
+// state file in dir
+func newTestGroup(t *testing.T, backend Backend, dir string, configure func(g *InstanceGroup)) *InstanceGroup {
+	t.Helper()
+
+	g := &InstanceGroup{
+		IncusGroup:          testGroup,
+		IncusStartupTimeout: 5,
+		StateFilePath:       filepath.Join(dir, "state.json"),
+		Backend:             backend,
+	}
+	if configure != nil {
+		configure(g)
+	}
+
+	if _, err := g.Init(context.Background(), hclog.NewNullLogger(), provider.Settings{}); err != nil {
+		t.Fatalf("Init: %v", err)
+	}
+
+	return g
+}
+
+// shutdown stops the background workers of g
+func shutdown(t *testing.T, g *InstanceGroup) {
+	t.Helper()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := g.Shutdown(ctx); err != nil {
+		t.Fatalf("Shutdown: %v", err)
+	}
+}
+
+// states runs Update and returns the reported states
+func states(t *testing.T, g *InstanceGroup) map[string]provider.State {
+	t.Helper()
+
+	reported := map[string]provider.State{}
+	err := g.Update(context.Background(), func(id string, state provider.State) {
+		reported[id] = state
+	})
+	if err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+
+	return reported
+}
+
+// waitForStates polls Update until every instance is reported in one of the wanted states
+func waitForStates(t *testing.T, g *InstanceGroup, want ...provider.State) map[string]provider.State {
+	t.Helper()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		reported := states(t, g)
+		settled := true
+		for _, state := range reported {
+			if !hasState(want, state) {
+				settled = false
+			}
+		}
+		if settled {
+			return reported
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("instances did not reach %v: %v", want, reported)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func hasState(states []provider.State, state provider.State) bool {
+	for _, s := range states {
+		if s == state {
+			return true
+		}
+	}
+	return false
+}
+
+// scaleUp increases the group by n and waits until all instances are running
+func scaleUp(t *testing.T, g *InstanceGroup, n int) []string {
+	t.Helper()
+
+	created, err := g.Increase(context.Background(), n)
+	if err != nil {
+		t.Fatalf("Increase: %v", err)
+	}
+	if created != n {
+		t.Fatalf("Increase created %d instances, want %d", created, n)
+	}
+
+	reported := waitForStates(t, g, provider.StateRunning)
+	names := make([]string, 0, len(reported))
+	for name := range reported {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+func TestScaleUp(t *testing.T) {
+	backend := newFakeBackend()
+	g := newTestGroup(t, backend, t.TempDir(), nil)
+	defer shutdown(t, g)
+
+	names := scaleUp(t, g, 3)
+	if len(names) != 3 || backend.count() != 3 {
+		t.Fatalf("got %d reported and %d created instances, want 3", len(names), backend.count())
+	}
+
+	for _, name := range names {
+		vm, err := backend.GetInstance(context.Background(), name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if vm.Group != testGroup {
+			t.Errorf("%s: group tag %q, want %q", name, vm.Group, testGroup)
+		}
+		if opts := backend.options(name); opts.CPU != 1 || opts.Memory != 2<<30 {
+			t.Errorf("%s: limits %d CPUs, %d bytes, want the c1-m2 default", name, opts.CPU, opts.Memory)
+		}
+
+		// Each instance authorizes its own key, which ConnectInfo hands out
+		info, err := g.ConnectInfo(context.Background(), name)
+		if err != nil {
+			t.Fatalf("ConnectInfo(%s): %v", name, err)
+		}
+		if info.InternalAddr != vm.Address || info.Protocol != provider.ProtocolSSH || info.Username != "root" {
+			t.Errorf("%s: unexpected connect info %+v", name, info)
+		}
+		if info.OS != "linux" || info.Arch != "amd64" {
+			t.Errorf("%s: platform %s/%s, want linux/amd64", name, info.OS, info.Arch)
+		}
+		publicKey, err := publicKeyFromPrivate(g.keys.path(name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		authorized := string(backend.file(name, "/root/.ssh/authorized_keys"))
+		if strings.TrimSpace(authorized) != publicKey {
+			t.Errorf("%s: authorized_keys %q does not hold the instance key %q", name, authorized, publicKey)
+		}
+	}
+}
+
+func TestConnectInfoFallsBackToStaticKey(t *testing.T) {
+	backend := newFakeBackend()
+	dir := t.TempDir()
+	staticKey, _, err := generateKeyPair()
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyPath := filepath.Join(dir, "id_ed25519")
+	if err := os.WriteFile(keyPath, staticKey, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	// Created by a version that used incus_instance_key_path for every instance
+	backend.add("runner-old", testGroup, "Running")
+	g := newTestGroup(t, backend, dir, func(g *InstanceGroup) {
+		g.IncusInstanceKeyPath = keyPath
+	})
+	defer shutdown(t, g)
+
+	info, err := g.ConnectInfo(context.Background(), "runner-old")
+	if err != nil {
+		t.Fatalf("ConnectInfo: %v", err)
+	}
+	if string(info.Key) != string(staticKey) {
+		t.Errorf("ConnectInfo handed out %q, want the key from incus_instance_key_path", info.Key)
+	}
+
+	// New instances still get their own key
+	names := scaleUp(t, g, 2)
+	for _, name := range names {
+		if name == "runner-old" {
+			continue
+		}
+		if info, err = g.ConnectInfo(context.Background(), name); err != nil || string(info.Key) == string(staticKey) {
+			t.Errorf("%s: ConnectInfo = %v, want its own key", name, err)
+		}
+	}
+}
+
+func TestInitInvalidSizes(t *testing.T) {
+	for _, configure := range []func(g *InstanceGroup){
+		func(g *InstanceGroup) { g.IncusInstanceSize = "c2m4" },
+		func(g *InstanceGroup) { g.IncusInstanceSize = "c2-m" },
+		func(g *InstanceGroup) { g.IncusDiskSize = "10GB " },
+		func(g *InstanceGroup) { g.IncusDiskSize = "2GiB" },
+	} {
+		g := &InstanceGroup{
+			IncusGroup:    testGroup,
+			StateFilePath: filepath.Join(t.TempDir(), "state.json"),
+			Backend:       newFakeBackend(),
+		}
+		configure(g)
+
+		if _, err := g.Init(context.Background(), hclog.NewNullLogger(), provider.Settings{}); err == nil {
+			t.Errorf("Init accepted size %q with disk %q", g.IncusInstanceSize, g.IncusDiskSize)
+			shutdown(t, g)
+		}
+	}
+}
+
+func TestScaleUpIncusInstanceType(t *testing.T) {
+	backend := newFakeBackend()
+	g := newTestGroup(t, backend, t.TempDir(), func(g *InstanceGroup) {
+		g.IncusInstanceSize = "gce:n1-standard-1"
+	})
+	defer shutdown(t, g)
+
+	// Incus resolves the type; no local limits on top
+	names := scaleUp(t, g, 1)
+	if opts := backend.options(names[0]); opts.Size != "gce:n1-standard-1" || opts.CPU != 0 || opts.Memory != 0 {
+		t.Errorf("%s: created with size %q, %d CPUs, %d bytes", names[0], opts.Size, opts.CPU, opts.Memory)
+	}
+}
+
+func TestScaleUpSlowBoot(t *testing.T) {
+	backend := newFakeBackend()
+	backend.bootDelay = 200 * time.Millisecond
+	g := newTestGroup(t, backend, t.TempDir(), nil)
+	defer shutdown(t, g)
+
+	if _, err := g.Increase(context.Background(), 2); err != nil {
+		t.Fatalf("Increase: %v", err)
+	}
+
+	// Increase returns before the instances are ready
+	for name, state := range states(t, g) {
+		if state != provider.StateCreating {
+			t.Errorf("%s: state %s right after Increase, want creating", name, state)
+		}
+	}
+
+	waitForStates(t, g, provider.StateRunning)
+}
+
+func TestScaleUpWhileCreating(t *testing.T) {
+	backend := newFakeBackend()
+	backend.bootDelay = 200 * time.Millisecond
+	g := newTestGroup(t, backend, t.TempDir(), nil)
+	defer shutdown(t, g)
+
+	// Instances still booting must not be subtracted from a second request
+	for range 2 {
+		created, err := g.Increase(context.Background(), 2)
+		if err != nil {
+			t.Fatalf("Increase: %v", err)
+		}
+		if created != 2 {
+			t.Fatalf("Increase created %d instances, want 2", created)
+		}
+	}
+
+	if reported := waitForStates(t, g, provider.StateRunning); len(reported) != 4 {
+		t.Errorf("got %d instances, want 4", len(reported))
+	}
+}
+
+func TestScaleUpMaxInstances(t *testing.T) {
+	backend := newFakeBackend()
+	backend.add("fleeting-adopted", testGroup, "Running")
+	g := newTestGroup(t, backend, t.TempDir(), func(g *InstanceGroup) {
+		g.MaxInstances = 3
+	})
+	defer shutdown(t, g)
+
+	// The adopted instance counts against the limit
+	states(t, g)
+
+	created, err := g.Increase(context.Background(), 5)
+	if err != nil {
+		t.Fatalf("Increase: %v", err)
+	}
+	if created != 2 {
+		t.Fatalf("Increase created %d instances, want 2", created)
+	}
+	waitForStates(t, g, provider.StateRunning)
+
+	created, err = g.Increase(context.Background(), 1)
+	if err != nil {
+		t.Fatalf("Increase: %v", err)
+	}
+	if created != 0 {
+		t.Errorf("Increase created %d instances at max_instances, want 0", created)
+	}
+	if n := backend.count(); n != 3 {
+		t.Errorf("backend has %d instances, want 3", n)
+	}
+}
+
+func TestScaleUpHostCapacity(t *testing.T) {
+	backend := newFakeBackend()
+	backend.host = incusprov.HostResources{CPUs: 2, Memory: 64 << 30}
+	g := newTestGroup(t, backend, t.TempDir(), func(g *InstanceGroup) {
+		g.IncusInstanceSize = "c2-m4"
+		g.IncusCPUOvercommit = 2
+	})
+	defer shutdown(t, g)
+
+	// 2 CPU threads at 2x overcommit fit two c2 instances
+	created, err := g.Increase(context.Background(), 3)
+	if err != nil {
+		t.Fatalf("Increase: %v", err)
+	}
+	if created != 2 {
+		t.Fatalf("Increase created %d instances, want 2", created)
+	}
+	waitForStates(t, g, provider.StateRunning)
+
+	// Unknown host resources do not block scale-ups
+	backend.mu.Lock()
+	backend.host, backend.hostErr = incusprov.HostResources{}, fmt.Errorf("simulated resources failure: %w", incusprov.ErrTransient)
+	backend.mu.Unlock()
+	if created, err = g.Increase(context.Background(), 1); err != nil || created != 1 {
+		t.Errorf("Increase without host resources = %d, %v, want 1", created, err)
+	}
+}
+
+func TestScaleUpStartupTimeout(t *testing.T) {
+	backend := newFakeBackend()
+	backend.neverBoots = true
+	g := newTestGroup(t, backend, t.TempDir(), func(g *InstanceGroup) {
+		g.IncusStartupTimeout = 1
+	})
+	defer shutdown(t, g)
+
+	if _, err := g.Increase(context.Background(), 1); err != nil {
+		t.Fatalf("Increase: %v", err)
+	}
+
+	// Timed out, then deleted once the retention policy removed it from Incus
+	waitForStates(t, g, provider.StateTimeout, provider.StateDeleted)
+	deadline := time.Now().Add(5 * time.Second)
+	for backend.count() != 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if backend.count() != 0 {
+		t.Errorf("timed out instance was not deleted")
+	}
+	if got := g.metrics.timedOut.Load(); got != 1 {
+		t.Errorf("timed out metric %d, want 1", got)
+	}
+}
+
+func TestScaleUpStartupTimeoutKeep(t *testing.T) {
+	backend := newFakeBackend()
+	backend.neverBoots = true
+	g := newTestGroup(t, backend, t.TempDir(), func(g *InstanceGroup) {
+		g.IncusStartupTimeout = 1
+		g.IncusFailureRetention = retentionKeep
+	})
+	defer shutdown(t, g)
+
+	if _, err := g.Increase(context.Background(), 1); err != nil {
+		t.Fatalf("Increase: %v", err)
+	}
+
+	waitForStates(t, g, provider.StateTimeout)
+	if backend.count() != 1 {
+		t.Errorf("timed out instance was not kept")
+	}
+}
+
+func TestScaleUpCreateFailure(t *testing.T) {
+	backend := newFakeBackend()
+	backend.createErr = fmt.Errorf("Image not found: %w", incusprov.ErrImageMissing)
+	g := newTestGroup(t, backend, t.TempDir(), nil)
+	defer shutdown(t, g)
+
+	if _, err := g.Increase(context.Background(), 2); err != nil {
+		t.Fatalf("Increase: %v", err)
+	}
+
+	waitForStates(t, g, provider.StateDeleted)
+	if got := g.metrics.failed.Load(); got != 2 {
+		t.Errorf("failed metric %d, want 2", got)
+	}
+
+	// A missing image fails fast instead of being retried
+	backend.mu.Lock()
+	defer backend.mu.Unlock()
+	if backend.creates != 2 {
+		t.Errorf("%d creation attempts, want 2", backend.creates)
+	}
+}
+
+func TestScaleUpRetriesTransientFailure(t *testing.T) {
+	backend := newFakeBackend()
+	backend.createErrs = []error{fmt.Errorf("Service Unavailable: %w", incusprov.ErrTransient)}
+	g := newTestGroup(t, backend, t.TempDir(), nil)
+	defer shutdown(t, g)
+
+	scaleUp(t, g, 1)
+
+	backend.mu.Lock()
+	defer backend.mu.Unlock()
+	if backend.creates != 2 {
+		t.Errorf("%d creation attempts, want 2", backend.creates)
+	}
+}
+
+func TestScaleUpSkipsForeignNames(t *testing.T) {
+	backend := newFakeBackend()
+	backend.add("runner", "other-group", "Running")
+	g := newTestGroup(t, backend, t.TempDir(), func(g *InstanceGroup) {
+		g.IncusNamingScheme = "runner"
+	})
+	defer shutdown(t, g)
+
+	if created, err := g.Increase(context.Background(), 1); err != nil || created != 0 {
+		t.Errorf("Increase = %d, %v, want 0 with the only name taken", created, err)
+	}
+	if !backend.exists("runner") {
+		t.Error("foreign instance was deleted")
+	}
+}
+
+func TestCreateInstanceNameClash(t *testing.T) {
+	backend := newFakeBackend()
+	g := newTestGroup(t, backend, t.TempDir(), nil)
+	defer shutdown(t, g)
+
+	// Created between the listing in Increase and the creation
+	backend.add("runner", "other-group", "Running")
+	err := g.createInstance(incusprov.CreateOptions{Name: "runner", Owner: incusprov.Owner{Group: testGroup}})
+	if !errors.Is(err, incusprov.ErrAlreadyExists) {
+		t.Errorf("createInstance = %v, want already exists", err)
+	}
+	if !backend.exists("runner") {
+		t.Error("foreign instance was deleted")
+	}
+
+	backend.mu.Lock()
+	defer backend.mu.Unlock()
+	if backend.creates != 1 || backend.deletes != 0 {
+		t.Errorf("%d creation attempts and %d deletions, want 1 and 0", backend.creates, backend.deletes)
+	}
+}
+
+func TestScaleUpRemovesLeftover(t *testing.T) {
+	backend := newFakeBackend()
+	backend.createErr = errors.New("Invalid config")
+	backend.leftover = true
+	backend.deleteFailures["runner"] = 1
+	g := newTestGroup(t, backend, t.TempDir(), func(g *InstanceGroup) {
+		g.IncusNamingScheme = "runner"
+	})
+	defer shutdown(t, g)
+
+	if _, err := g.Increase(context.Background(), 1); err != nil {
+		t.Fatalf("Increase: %v", err)
+	}
+
+	// The cleanup after the failed creation fails; the instance stays deleting until Update
+	// removed it
+	waitForStates(t, g, provider.StateDeleted)
+	if backend.exists("runner") {
+		t.Error("half-created instance leaked")
+	}
+}
+
+func TestCircuitBreakerPausesScaleUp(t *testing.T) {
+	backend := newFakeBackend()
+	backend.createErr = fmt.Errorf("Image not found: %w", incusprov.ErrImageMissing)
+	g := newTestGroup(t, backend, t.TempDir(), func(g *InstanceGroup) {
+		g.IncusBreakerThreshold = 2
+	})
+	defer shutdown(t, g)
+	g.breaker.coolDown = 100 * time.Millisecond
+
+	if _, err := g.Increase(context.Background(), 2); err != nil {
+		t.Fatalf("Increase: %v", err)
+	}
+	waitForStates(t, g, provider.StateDeleted)
+	if !g.metrics.breakerOpen.Load() || g.metrics.breakerTrips.Load() != 1 {
+		t.Fatalf("breaker not open after 2 failures")
+	}
+
+	// Refused while open
+	if created, err := g.Increase(context.Background(), 3); err != nil || created != 0 {
+		t.Fatalf("Increase while open = %d, %v, want 0", created, err)
+	}
+
+	// One trial after the cool-down; its success closes the breaker
+	time.Sleep(150 * time.Millisecond)
+	backend.mu.Lock()
+	backend.createErr = nil
+	backend.mu.Unlock()
+	if created, err := g.Increase(context.Background(), 3); err != nil || created != 1 {
+		t.Fatalf("Increase while half-open = %d, %v, want 1", created, err)
+	}
+	waitForStates(t, g, provider.StateDeleted, provider.StateRunning)
+	if g.metrics.breakerOpen.Load() {
+		t.Errorf("breaker still open after successful trial")
+	}
+	if created, err := g.Increase(context.Background(), 3); err != nil || created != 3 {
+		t.Errorf("Increase after closing = %d, %v, want 3", created, err)
+	}
+}
+
+func TestScaleUpInstanceVanishesDuringBoot(t *testing.T) {
+	backend := newFakeBackend()
+	backend.neverBoots = true
+	g := newTestGroup(t, backend, t.TempDir(), nil)
+	defer shutdown(t, g)
+
+	if _, err := g.Increase(context.Background(), 1); err != nil {
+		t.Fatalf("Increase: %v", err)
+	}
+	var name string
+	for id := range states(t, g) {
+		name = id
+	}
+	for !backend.exists(name) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	backend.remove(name)
+
+	// Reported deleted well before the startup timeout
+	waitForStates(t, g, provider.StateDeleted)
+	if got := g.metrics.timedOut.Load(); got != 0 {
+		t.Errorf("timed out metric %d, want 0", got)
+	}
+}
+
+func TestScaleDown(t *testing.T) {
+	backend := newFakeBackend()
+	g := newTestGroup(t, backend, t.TempDir(), nil)
+	defer shutdown(t, g)
+
+	names := scaleUp(t, g, 3)
+
+	removed, err := g.Decrease(context.Background(), names[:2])
+	if err != nil {
+		t.Fatalf("Decrease: %v", err)
+	}
+	if len(removed) != 2 {
+		t.Fatalf("Decrease removed %v, want %v", removed, names[:2])
+	}
+
+	reported := waitForStates(t, g, provider.StateRunning, provider.StateDeleted)
+	for _, name := range names[:2] {
+		if reported[name] != provider.StateDeleted {
+			t.Errorf("%s: state %s, want deleted", name, reported[name])
+		}
+		if backend.exists(name) {
+			t.Errorf("%s: still exists in Incus", name)
+		}
+		if _, err := os.Stat(g.keys.path(name)); !os.IsNotExist(err) {
+			t.Errorf("%s: SSH key was not removed", name)
+		}
+	}
+	if reported[names[2]] != provider.StateRunning {
+		t.Errorf("%s: state %s, want running", names[2], reported[names[2]])
+	}
+}
+
+func TestScaleDownRetriesFailedDeletion(t *testing.T) {
+	backend := newFakeBackend()
+	g := newTestGroup(t, backend, t.TempDir(), nil)
+	defer shutdown(t, g)
+
+	names := scaleUp(t, g, 1)
+
+	// Fails more often than one worker retries, so Update has to queue it again
+	backend.mu.Lock()
+	backend.deleteFailures[names[0]] = deleteAttempts + 1
+	backend.mu.Unlock()
+
+	if _, err := g.Decrease(context.Background(), names); err != nil {
+		t.Fatalf("Decrease: %v", err)
+	}
+
+	waitForStates(t, g, provider.StateDeleted)
+	if backend.exists(names[0]) {
+		t.Errorf("%s: still exists in Incus", names[0])
+	}
+}
+
+func TestScaleDownSkipsForeignInstances(t *testing.T) {
+	backend := newFakeBackend()
+	backend.add("foreign", "other-group", "Running")
+	g := newTestGroup(t, backend, t.TempDir(), nil)
+	defer shutdown(t, g)
+
+	removed, err := g.Decrease(context.Background(), []string{"foreign"})
+	if err != nil {
+		t.Fatalf("Decrease: %v", err)
+	}
+	if len(removed) != 0 {
+		t.Errorf("Decrease removed foreign instance: %v", removed)
+	}
+	if _, tracked := states(t, g)["foreign"]; tracked {
+		t.Errorf("foreign instance is reported")
+	}
+	if !backend.exists("foreign") {
+		t.Errorf("foreign instance was deleted")
+	}
+}
+
+func TestSourceInstance(t *testing.T) {
+	backend := newFakeBackend()
+	// A golden instance copied from a runner still carries our group tag
+	backend.add("golden", testGroup, "Stopped")
+	backend.instances["golden"].snapshots = []string{"snap0"}
+	dir := t.TempDir()
+	if err := save(filepath.Join(dir, "state.json"), map[string]provider.State{"golden": provider.StateRunning}, nil); err != nil {
+		t.Fatal(err)
+	}
+	g := newTestGroup(t, backend, dir, func(g *InstanceGroup) {
+		g.IncusSourceInstance = "golden"
+		g.IncusSourceSnapshot = "snap0"
+	})
+	defer shutdown(t, g)
+
+	// Never reported, even if the state file lists it
+	if state, tracked := states(t, g)["golden"]; tracked && state != provider.StateDeleted {
+		t.Errorf("golden instance reported as %s", state)
+	}
+
+	names := scaleUp(t, g, 1)
+	if opts := backend.options(names[0]); opts.Source != "golden/snap0" {
+		t.Errorf("%s: created from %q, want golden/snap0", names[0], opts.Source)
+	}
+
+	removed, err := g.Decrease(context.Background(), []string{"golden"})
+	if err != nil {
+		t.Fatalf("Decrease: %v", err)
+	}
+	if len(removed) != 0 {
+		t.Errorf("Decrease removed the golden instance: %v", removed)
+	}
+	g.cleanupAllStaleVMs(context.Background())
+	waitForStates(t, g, provider.StateRunning, provider.StateDeleted)
+	if !backend.exists("golden") {
+		t.Error("golden instance was deleted")
+	}
+}
+
+func TestSourceInstanceSnapshotsNotCopied(t *testing.T) {
+	backend := newFakeBackend()
+	backend.add("golden", "", "Stopped")
+	backend.instances["golden"].snapshots = []string{"snap0", "snap1"}
+	g := newTestGroup(t, backend, t.TempDir(), func(g *InstanceGroup) {
+		g.IncusSourceInstance = "golden"
+	})
+	defer shutdown(t, g)
+
+	names := scaleUp(t, g, 1)
+	if opts := backend.options(names[0]); opts.Source != "golden" || !opts.InstanceOnly() {
+		t.Errorf("%s: created from %q, instance only %v, want golden without snapshots", names[0], opts.Source, opts.InstanceOnly())
+	}
+	if snapshots := backend.snapshots(names[0]); len(snapshots) != 0 {
+		t.Errorf("%s: copied with the golden instance's snapshots %v", names[0], snapshots)
+	}
+}
+
+func TestSourceInstanceMissing(t *testing.T) {
+	g := &InstanceGroup{
+		IncusGroup:          testGroup,
+		IncusSourceInstance: "golden",
+		StateFilePath:       filepath.Join(t.TempDir(), "state.json"),
+		Backend:             newFakeBackend(),
+	}
+	if _, err := g.Init(context.Background(), hclog.NewNullLogger(), provider.Settings{}); err == nil {
+		shutdown(t, g)
+		t.Fatal("Init succeeded without the source instance")
+	}
+}
+
+func TestCrashedInstances(t *testing.T) {
+	backend := newFakeBackend()
+	g := newTestGroup(t, backend, t.TempDir(), nil)
+	defer shutdown(t, g)
+
+	names := scaleUp(t, g, 2)
+
+	backend.setStatus(names[0], "Stopped")
+	backend.remove(names[1])
+
+	reported := states(t, g)
+	if reported[names[0]] != provider.StateTimeout {
+		t.Errorf("stopped instance: state %s, want timeout", reported[names[0]])
+	}
+	if reported[names[1]] != provider.StateDeleted {
+		t.Errorf("vanished instance: state %s, want deleted", reported[names[1]])
+	}
+}
+
+func TestRestart(t *testing.T) {
+	backend := newFakeBackend()
+	dir := t.TempDir()
+
+	g := newTestGroup(t, backend, dir, nil)
+	names := scaleUp(t, g, 3)
+	if _, err := g.Decrease(context.Background(), names[:1]); err != nil {
+		t.Fatalf("Decrease: %v", err)
+	}
+	waitForStates(t, g, provider.StateRunning, provider.StateDeleted)
+	shutdown(t, g)
+
+	// Changes while the plugin was down
+	backend.remove(names[1])
+	backend.add("adopted", testGroup, "Running")
+	backend.add("foreign", "other-group", "Running")
+
+	g = newTestGroup(t, backend, dir, nil)
+	defer shutdown(t, g)
+
+	reported := states(t, g)
+	want := map[string]provider.State{
+		names[1]:  provider.StateDeleted,
+		names[2]:  provider.StateRunning,
+		"adopted": provider.StateRunning,
+	}
+	if len(reported) != len(want) {
+		t.Errorf("reported %v, want %v", reported, want)
+	}
+	for name, state := range want {
+		if reported[name] != state {
+			t.Errorf("%s: state %s, want %s", name, reported[name], state)
+		}
+	}
+}
+
+func TestRestartResumesDeletion(t *testing.T) {
+	backend := newFakeBackend()
+	dir := t.TempDir()
+
+	g := newTestGroup(t, backend, dir, nil)
+	names := scaleUp(t, g, 1)
+	shutdown(t, g)
+
+	// The plugin stopped before the deletion went through
+	status := map[string]provider.State{names[0]: provider.StateDeleting}
+	if err := save(filepath.Join(dir, "state.json"), status, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	g = newTestGroup(t, backend, dir, nil)
+	defer shutdown(t, g)
+
+	waitForStates(t, g, provider.StateDeleted)
+	if backend.exists(names[0]) {
+		t.Errorf("%s: still exists in Incus", names[0])
+	}
+}
+
+func TestRestartDropsInterruptedCreation(t *testing.T) {
+	backend := newFakeBackend()
+	dir := t.TempDir()
+
+	g := newTestGroup(t, backend, dir, nil)
+	names := scaleUp(t, g, 1)
+	shutdown(t, g)
+
+	// The plugin was killed while creating both; only the first reached Incus
+	status := map[string]provider.State{names[0]: provider.StateCreating, "runner-orphan": provider.StateCreating}
+	if err := save(filepath.Join(dir, "state.json"), status, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	g = newTestGroup(t, backend, dir, func(g *InstanceGroup) {
+		g.MaxInstances = 1
+	})
+	defer shutdown(t, g)
+
+	waitForStates(t, g, provider.StateDeleted)
+	if backend.exists(names[0]) {
+		t.Errorf("%s: half-created instance still exists in Incus", names[0])
+	}
+
+	// Neither holds a slot of max_instances any more
+	if created, err := g.Increase(context.Background(), 1); err != nil || created != 1 {
+		t.Errorf("Increase after restart = %d, %v, want 1", created, err)
+	}
+}
+
+func TestCleanupStaleVMs(t *testing.T) {
+	backend := newFakeBackend()
+	g := newTestGroup(t, backend, t.TempDir(), nil)
+	defer shutdown(t, g)
+
+	names := scaleUp(t, g, 2)
+	backend.remove(names[0])
+
+	if cleaned := g.cleanupAllStaleVMs(context.Background()); cleaned != 1 {
+		t.Errorf("cleaned %d stale instances, want 1", cleaned)
+	}
+
+	reported := states(t, g)
+	if _, tracked := reported[names[0]]; tracked {
+		t.Errorf("%s: stale instance still tracked", names[0])
+	}
+	if reported[names[1]] != provider.StateRunning {
+		t.Errorf("%s: state %s, want running", names[1], reported[names[1]])
+	}
+}
+
+func TestStateFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.json")
+	status := map[string]provider.State{
+		"a": provider.StateRunning,
+		"b": provider.StateDeleting,
+	}
+	members := map[string]string{"a": "node1", "gone": "node2"}
+	if err := save(path, status, members); err != nil {
+		t.Fatal(err)
+	}
+
+	loaded, loadedMembers, err := load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(loaded) != len(status) || loaded["a"] != provider.StateRunning || loaded["b"] != provider.StateDeleting {
+		t.Errorf("loaded %v, want %v", loaded, status)
+	}
+	// Members of untracked instances are not kept
+	if len(loadedMembers) != 1 || loadedMembers["a"] != "node1" {
+		t.Errorf("loaded members %v, want a on node1", loadedMembers)
+	}
+
+	// State files of older versions hold the instances alone
+	if err := os.WriteFile(path, []byte(`{"a": "running", "instances": "deleting"}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	loaded, loadedMembers, err = load(path)
+	if err != nil || len(loaded) != 2 || loaded["instances"] != provider.StateDeleting || len(loadedMembers) != 0 {
+		t.Errorf("load of legacy file: %v, %v, %v", loaded, loadedMembers, err)
+	}
+
+	// A missing state file starts empty
+	loaded, loadedMembers, err = load(filepath.Join(t.TempDir(), "missing.json"))
+	if err == nil || len(loaded) != 0 || loadedMembers == nil {
+		t.Errorf("load of missing file: %v, %v", loaded, err)
+	}
+}
@@ -0,0 +1,63 @@
+package fleetingincus
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// keyStore keeps one ephemeral SSH private key per instance on disk, so keys
+// survive plugin restarts while the instance is alive
+type keyStore struct {
+	dir string
+}
+
+// generateKeyPair creates a fresh ed25519 key pair, returning the PEM private key
+// and the public key in authorized_keys format
+func generateKeyPair() (privateKey []byte, authorizedKey string, err error) {
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to generate ed25519 key: %w", err)
+	}
+
+	der, err := x509.MarshalPKCS8PrivateKey(priv)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to marshal private key: %w", err)
+	}
+
+	sshPub, err := ssh.NewPublicKey(pub)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to convert public key: %w", err)
+	}
+
+	privateKey = pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})
+	authorizedKey = strings.TrimSpace(string(ssh.MarshalAuthorizedKey(sshPub)))
+	return
+}
+
+func (k keyStore) path(name string) string {
+	return filepath.Join(k.dir, name)
+}
+
+func (k keyStore) save(name string, privateKey []byte) error {
+	if err := os.MkdirAll(k.dir, 0700); err != nil {
+		return err
+	}
+
+	return os.WriteFile(k.path(name), privateKey, 0600)
+}
+
+func (k keyStore) remove(name string) error {
+	err := os.Remove(k.path(name))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
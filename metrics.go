@@ -0,0 +1,88 @@
+package fleetingincus
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/hashicorp/go-hclog"
+)
+
+// metrics holds plugin counters. They are logged with scale operations and, when
+// metrics_listen_address is set, served in Prometheus text format on /metrics.
+type metrics struct {
+	created  atomic.Int64 // Instances that became ready
+	failed   atomic.Int64 // Creations that failed before or during provisioning
+	timedOut atomic.Int64 // Instances that never passed their readiness probes
+	deleted  atomic.Int64 // Instances removed from Incus
+	recycled atomic.Int64 // Instances returned to the warm pool instead of being deleted
+
+	breakerOpen  atomic.Bool  // Creation circuit breaker is open or half-open
+	breakerTrips atomic.Int64 // Times the circuit breaker opened
+
+	poolReady atomic.Int64 // Stopped instances waiting in the warm pool
+
+	server *http.Server
+}
+
+// serve starts the metrics endpoint in the background
+func (m *metrics) serve(addr string, log hclog.Logger) error {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return fmt.Errorf("failed to listen on metrics address %s: %w", addr, err)
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/metrics", m.handle)
+	m.server = &http.Server{Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+
+	go func() {
+		if err := m.server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Error("❌ [METRICS] Metrics server stopped", "error", err)
+		}
+	}()
+
+	return nil
+}
+
+// shutdown stops the metrics endpoint, if running
+func (m *metrics) shutdown(ctx context.Context) error {
+	if m.server == nil {
+		return nil
+	}
+	return m.server.Shutdown(ctx)
+}
+
+func (m *metrics) handle(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+
+	counter(w, "fleeting_incus_instances_created_total", "Instances that became ready.", m.created.Load())
+	counter(w, "fleeting_incus_instances_failed_total", "Instance creations that failed.", m.failed.Load())
+	counter(w, "fleeting_incus_instances_timed_out_total", "Instances that did not pass readiness probes within the startup timeout.", m.timedOut.Load())
+	counter(w, "fleeting_incus_instances_deleted_total", "Instances removed from Incus.", m.deleted.Load())
+	counter(w, "fleeting_incus_instances_recycled_total", "Instances returned to the warm pool instead of being deleted.", m.recycled.Load())
+	counter(w, "fleeting_incus_circuit_breaker_trips_total", "Times repeated creation failures paused scale ups.", m.breakerTrips.Load())
+
+	var open int64
+	if m.breakerOpen.Load() {
+		open = 1
+	}
+	gauge(w, "fleeting_incus_circuit_breaker_open", "Whether scale ups are paused by the creation circuit breaker.", open)
+	gauge(w, "fleeting_incus_warm_pool_ready", "Stopped instances waiting in the warm pool.", m.poolReady.Load())
+}
+
+func counter(w http.ResponseWriter, name, help string, value int64) {
+	metric(w, "counter", name, help, value)
+}
+
+func gauge(w http.ResponseWriter, name, help string, value int64) {
+	metric(w, "gauge", name, help, value)
+}
+
+func metric(w http.ResponseWriter, kind, name, help string, value int64) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n%s %d\n", name, help, name, kind, name, value)
+}
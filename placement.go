@@ -0,0 +1,162 @@
+package fleetingincus
+
+import (
+	"context"
+	"slices"
+	"strings"
+
+	"fleeting-plugin-incus/incusprov"
+)
+
+// Placement strategies for incus_placement
+const (
+	placementOff         = "off"          // Leave it to incus_target or the Incus scheduler
+	placementSpread      = "spread"       // Member with the fewest instances of this group
+	placementPack        = "pack"         // Member with the most instances of this group that still has room
+	placementLeastLoaded = "least-loaded" // Member with the lowest CPU load or memory use
+)
+
+// placementPlan picks cluster members for a batch of new instances. Each pick counts towards
+// the member, so a batch is not piled onto the member that looked best at the start.
+type placementPlan struct {
+	strategy string
+	cpus     int   // Per instance
+	memory   int64 // Per instance, in bytes
+	members  []*memberLoad
+}
+
+// memberLoad is a candidate member with the instances and resources planned on top of it
+type memberLoad struct {
+	incusprov.ClusterMember
+	instances int     // Instances of this group, existing and planned
+	cpus      float64 // Planned vCPUs
+	memory    uint64  // Planned memory in bytes
+}
+
+// planPlacement gathers the candidate members for incus_placement: online members, of the
+// cluster group if incus_target names one. It returns nil if placement is off or the members
+// cannot be read, which leaves placement to Incus.
+func (g *InstanceGroup) planPlacement(ctx context.Context, vms map[string]incusprov.VMInfo) *placementPlan {
+	if g.IncusPlacement == placementOff {
+		return nil
+	}
+
+	members, err := g.Backend.GetClusterMembers(ctx)
+	if err == nil && vms == nil {
+		vms, err = g.Backend.ListInstances(ctx)
+	}
+	if err != nil {
+		g.log.Warn("⚠️ [PLACEMENT] Failed to read cluster members, leaving placement to Incus", "error", err, "error_class", errorClass(err))
+		return nil
+	}
+
+	counts := map[string]int{}
+	for _, vm := range vms {
+		if vm.Group == g.IncusGroup && !g.protected(vm.Name) {
+			counts[vm.Location]++
+		}
+	}
+
+	plan := &placementPlan{strategy: g.IncusPlacement, cpus: g.size.cpus, memory: g.size.memory}
+	group, inGroup := strings.CutPrefix(g.IncusTarget, "@")
+	for _, member := range members {
+		if !member.Online || (inGroup && !slices.Contains(member.Groups, group)) {
+			continue
+		}
+		plan.members = append(plan.members, &memberLoad{ClusterMember: member, instances: counts[member.Name]})
+	}
+	if len(plan.members) == 0 {
+		g.log.Warn("⚠️ [PLACEMENT] No online cluster member to place instances on, leaving placement to Incus", "target", g.IncusTarget)
+		return nil
+	}
+
+	return plan
+}
+
+// pick chooses the member for the next instance, or "" without a plan
+func (p *placementPlan) pick() string {
+	if p == nil {
+		return ""
+	}
+
+	var best *memberLoad
+	switch p.strategy {
+	case placementSpread:
+		for _, m := range p.members {
+			if best == nil || m.instances < best.instances {
+				best = m
+			}
+		}
+	case placementPack:
+		for _, m := range p.members {
+			if p.fits(m) && (best == nil || m.instances > best.instances) {
+				best = m
+			}
+		}
+		if best == nil {
+			// Every member is full; the least loaded one is the best bet
+			best = p.leastLoaded()
+		}
+	default:
+		best = p.leastLoaded()
+	}
+
+	best.instances++
+	best.cpus += float64(p.cpus)
+	best.memory += uint64(p.memory)
+	return best.Name
+}
+
+// capacity returns host with CPU threads and memory replaced by the totals of the candidate
+// members, so admission control covers all members instances may be placed on. A total is
+// unknown (zero) if any member does not report it.
+func (p *placementPlan) capacity(host incusprov.HostResources) incusprov.HostResources {
+	var cpus, memory uint64
+	cpusKnown, memoryKnown := true, true
+	for _, m := range p.members {
+		cpus += m.CPUs
+		memory += m.Memory
+		cpusKnown = cpusKnown && m.CPUs > 0
+		memoryKnown = memoryKnown && m.Memory > 0
+	}
+	if !cpusKnown {
+		cpus = 0
+	}
+	if !memoryKnown {
+		memory = 0
+	}
+	host.CPUs, host.Memory = cpus, memory
+	return host
+}
+
+// fits reports whether the free memory of a member still takes another instance. Members that
+// report no memory figures always fit.
+func (p *placementPlan) fits(m *memberLoad) bool {
+	return m.Memory == 0 || m.MemoryFree >= m.memory+uint64(p.memory)
+}
+
+// leastLoaded returns the member whose CPU load per thread or memory use, whichever is
+// higher, is lowest
+func (p *placementPlan) leastLoaded() *memberLoad {
+	var best *memberLoad
+	bestLoad := 0.0
+	for _, m := range p.members {
+		if load := m.load(); best == nil || load < bestLoad {
+			best, bestLoad = m, load
+		}
+	}
+	return best
+}
+
+// load is the share of the member in use, counting what is planned on it
+func (m *memberLoad) load() float64 {
+	var cpu, memory float64
+	if m.CPUs > 0 {
+		cpu = (m.Load + m.cpus) / float64(m.CPUs)
+	}
+	if m.Memory > 0 {
+		used := float64(m.Memory) - float64(m.MemoryFree) + float64(m.memory)
+		memory = used / float64(m.Memory)
+	}
+	return max(cpu, memory)
+}
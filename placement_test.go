@@ -0,0 +1,188 @@
+package fleetingincus
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"fleeting-plugin-incus/incusprov"
+
+	"github.com/hashicorp/go-hclog"
+	"gitlab.com/gitlab-org/fleeting/fleeting/provider"
+)
+
+// testCluster has three online members with 8 CPU threads and 32GiB of memory each (node3 is
+// busy) and an offline one
+func testCluster() []incusprov.ClusterMember {
+	return []incusprov.ClusterMember{
+		{Name: "node1", Groups: []string{"default", "runners"}, Online: true, CPUs: 8, Memory: 32 << 30, MemoryFree: 24 << 30, Load: 2},
+		{Name: "node2", Groups: []string{"default", "runners"}, Online: true, CPUs: 8, Memory: 32 << 30, MemoryFree: 20 << 30, Load: 1},
+		{Name: "node3", Groups: []string{"default"}, Online: true, CPUs: 8, Memory: 32 << 30, MemoryFree: 4 << 30, Load: 7},
+		{Name: "node4", Groups: []string{"default", "runners"}},
+	}
+}
+
+func TestPlacementPick(t *testing.T) {
+	tests := []struct {
+		strategy string
+		counts   map[string]int // Instances of the group per member
+		want     []string
+	}{
+		// Fewest instances first, offline node4 never
+		{placementSpread, map[string]int{"node1": 1}, []string{"node2", "node3", "node1", "node2"}},
+		// Most instances first, as long as 8GiB fit into free memory
+		{placementPack, map[string]int{"node2": 1}, []string{"node2", "node2", "node1", "node1", "node1", "node2"}},
+		// The higher of CPU load and memory use counts; node3 is short of both
+		{placementLeastLoaded, nil, []string{"node1", "node2", "node1", "node2"}},
+	}
+
+	for _, tt := range tests {
+		g := &InstanceGroup{
+			IncusGroup:     testGroup,
+			IncusPlacement: tt.strategy,
+			Backend:        &fakeBackend{members: testCluster()},
+			log:            hclog.NewNullLogger(),
+			size:           instanceResources{cpus: 2, memory: 8 << 30},
+		}
+		vms := map[string]incusprov.VMInfo{}
+		for member, n := range tt.counts {
+			for i := range n {
+				name := member + "-" + string(rune('a'+i))
+				vms[name] = incusprov.VMInfo{Name: name, Group: testGroup, Location: member}
+			}
+		}
+
+		plan := g.planPlacement(context.Background(), vms)
+		for i, want := range tt.want {
+			if got := plan.pick(); got != want {
+				t.Errorf("%s: pick %d = %s, want %s", tt.strategy, i+1, got, want)
+			}
+		}
+	}
+}
+
+func TestPlacementClusterGroup(t *testing.T) {
+	g := &InstanceGroup{
+		IncusGroup:     testGroup,
+		IncusTarget:    "@runners",
+		IncusPlacement: placementLeastLoaded,
+		Backend:        &fakeBackend{members: testCluster()},
+		log:            hclog.NewNullLogger(),
+		size:           instanceResources{cpus: 1, memory: 1 << 30},
+	}
+
+	plan := g.planPlacement(context.Background(), map[string]incusprov.VMInfo{})
+	for range 10 {
+		if member := plan.pick(); member == "node3" {
+			t.Fatal("picked node3 outside the runners group")
+		}
+	}
+
+	// Placement is left to Incus if it has nothing to choose from
+	g.IncusTarget = "@gpu"
+	if plan := g.planPlacement(context.Background(), map[string]incusprov.VMInfo{}); plan.pick() != "" {
+		t.Error("placed an instance without candidate members")
+	}
+}
+
+func TestScaleUpPlacement(t *testing.T) {
+	backend := newFakeBackend()
+	backend.members = testCluster()
+	dir := t.TempDir()
+	g := newTestGroup(t, backend, dir, func(g *InstanceGroup) {
+		g.IncusPlacement = placementSpread
+	})
+
+	names := scaleUp(t, g, 3)
+	located := map[string]string{}
+	for _, name := range names {
+		member := backend.located(name)
+		if other, taken := located[member]; taken {
+			t.Errorf("%s and %s both on %s, want them spread", name, other, member)
+		}
+		located[member] = name
+	}
+	shutdown(t, g)
+
+	// The chosen members are kept in the state file
+	_, members, err := load(filepath.Join(dir, "state.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range names {
+		if members[name] != backend.located(name) {
+			t.Errorf("%s: recorded on %q, created on %q", name, members[name], backend.located(name))
+		}
+	}
+}
+
+func TestScaleUpTarget(t *testing.T) {
+	backend := newFakeBackend()
+	backend.members = testCluster()
+	g := newTestGroup(t, backend, t.TempDir(), func(g *InstanceGroup) {
+		g.IncusTarget = "@runners"
+	})
+	defer shutdown(t, g)
+
+	// Incus picks the member within the group; Update records it
+	names := scaleUp(t, g, 2)
+	states(t, g)
+	for _, name := range names {
+		if opts := backend.options(name); opts.Target != "" {
+			t.Errorf("%s: created with target %q, want the client's", name, opts.Target)
+		}
+		g.m.Lock()
+		member := g.members[name]
+		g.m.Unlock()
+		if member != backend.located(name) || member == "" {
+			t.Errorf("%s: recorded on %q, created on %q", name, member, backend.located(name))
+		}
+	}
+}
+
+func TestInitInvalidPlacement(t *testing.T) {
+	for _, tt := range []struct {
+		target, placement string
+		members           []incusprov.ClusterMember
+	}{
+		{"", "round-robin", testCluster()},
+		{"node1", placementSpread, testCluster()},
+		{"", placementPack, nil}, // Standalone server
+	} {
+		g := &InstanceGroup{
+			IncusGroup:     testGroup,
+			IncusTarget:    tt.target,
+			IncusPlacement: tt.placement,
+			StateFilePath:  filepath.Join(t.TempDir(), "state.json"),
+			Backend:        &fakeBackend{members: tt.members, instances: map[string]*fakeInstance{}},
+		}
+		if _, err := g.Init(context.Background(), hclog.NewNullLogger(), provider.Settings{}); err == nil {
+			t.Errorf("Init accepted placement %q with target %q on %d members", tt.placement, tt.target, len(tt.members))
+			shutdown(t, g)
+		}
+	}
+}
+
+func TestScaleUpClusterCapacity(t *testing.T) {
+	backend := newFakeBackend()
+	backend.members = testCluster()
+	// The member answering the API call is one of them
+	backend.host = incusprov.HostResources{CPUs: 8, Memory: 32 << 30}
+	g := newTestGroup(t, backend, t.TempDir(), func(g *InstanceGroup) {
+		g.IncusPlacement = placementSpread
+		g.IncusInstanceSize = "c2-m4"
+		g.IncusCPUOvercommit = 1
+		g.MaxInstances = 20
+	})
+	defer shutdown(t, g)
+
+	// 24 CPU threads on the three online members fit twelve c2 instances, not the answering
+	// member's four
+	created, err := g.Increase(context.Background(), 14)
+	if err != nil {
+		t.Fatalf("Increase: %v", err)
+	}
+	if created != 12 {
+		t.Errorf("Increase created %d instances, want 12", created)
+	}
+}
@@ -0,0 +1,239 @@
+package fleetingincus
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"slices"
+	"sort"
+	"time"
+
+	"fleeting-plugin-incus/incusprov"
+)
+
+// poolRefillInterval is how often the warm pool is checked without a scale up asking for it
+// (a variable so tests can shorten it)
+var poolRefillInterval = 30 * time.Second
+
+// runPool keeps incus_warm_pool_size stopped instances of the configured image around until
+// the plugin shuts down. Pooled instances live only in Incus, tagged with incusprov.ConfigKeyPool;
+// they are found again after a restart and are never reported to fleeting until claimed.
+func (g *InstanceGroup) runPool() {
+	defer g.wg.Done()
+
+	ticker := time.NewTicker(poolRefillInterval)
+	defer ticker.Stop()
+
+	for {
+		g.refillPool(g.ctx)
+
+		select {
+		case <-g.ctx.Done():
+			return
+		case <-ticker.C:
+		case <-g.poolWake:
+		}
+	}
+}
+
+// wakePool asks the refiller to top up the pool now, e.g. after instances were claimed
+func (g *InstanceGroup) wakePool() {
+	select {
+	case g.poolWake <- struct{}{}:
+	default:
+	}
+}
+
+// refillPool rebuilds pooled instances of another image and creates missing ones
+func (g *InstanceGroup) refillPool(ctx context.Context) {
+	// Instances that join the pool while Incus is listed (e.g. recycled ones) may be missing
+	// from the listing; only those pooled before are known to be gone if missing
+	g.m.Lock()
+	before := slices.Clone(g.pool)
+	g.m.Unlock()
+
+	vms, err := g.Backend.ListInstances(ctx)
+	if err != nil {
+		if ctx.Err() == nil {
+			g.log.Warn("⚠️ [POOL] Failed to list instances, pool not refilled", "error", err, "error_class", errorClass(err))
+		}
+		return
+	}
+	fingerprint := g.imageFingerprint(ctx)
+
+	g.m.Lock()
+	var ready, stale []string
+	for name, vm := range vms {
+		if !vm.Pool || vm.Group != g.IncusGroup {
+			continue
+		}
+		// Claimed by Increase and being started
+		if _, claimed := g.status[name]; claimed {
+			continue
+		}
+		if g.outdated(vm, fingerprint) || vm.Status != "Stopped" {
+			stale = append(stale, name)
+			continue
+		}
+		ready = append(ready, name)
+	}
+	for _, name := range g.pool {
+		if _, listed := vms[name]; !listed && !slices.Contains(before, name) {
+			ready = append(ready, name)
+		}
+	}
+	sort.Strings(ready)
+	g.pool = ready
+	// Recycled instances are on their way back
+	missing := g.IncusWarmPoolSize - len(ready) - g.recycling
+	g.metrics.poolReady.Store(int64(len(ready)))
+	g.m.Unlock()
+
+	for _, name := range stale {
+		g.log.Info("♻️ [POOL] Rebuilding pooled VM",
+			"vm_name", name,
+			"image", vms[name].Image,
+			"base_image", vms[name].BaseImage,
+			"status", vms[name].Status,
+			"want_image", g.origin(),
+			"want_base_image", fingerprint)
+		if err := g.Backend.DeleteInstance(ctx, name); err != nil {
+			g.log.Warn("⚠️ [POOL] Failed to delete pooled VM", "vm_name", name, "error", err, "error_class", errorClass(err))
+		}
+	}
+
+	if missing <= 0 {
+		return
+	}
+	// Creation keeps failing; the trial instance of a scale up decides when to try again
+	if g.breaker.state(time.Now()) != breakerClosed {
+		g.log.Info("⏸️ [POOL] Pool not refilled, creation circuit is open", "missing", missing)
+		return
+	}
+
+	g.log.Info("🧊 [POOL] Refilling warm pool", "ready", len(ready), "missing", missing)
+	plan := g.planPlacement(ctx, vms)
+	for range missing {
+		name, ok := g.poolName(vms)
+		if !ok {
+			return
+		}
+		if !g.createPooled(ctx, name, plan.pick()) {
+			return
+		}
+	}
+}
+
+// poolName picks an unused name for a pooled instance
+func (g *InstanceGroup) poolName(vms map[string]incusprov.VMInfo) (string, bool) {
+	g.m.Lock()
+	defer g.m.Unlock()
+
+	for range 10 {
+		name := os.Expand(g.IncusNamingScheme, naming)
+		_, tracked := g.status[name]
+		_, exists := vms[name]
+		if !tracked && !exists && !g.pooled(name) && !g.protected(name) {
+			return name, true
+		}
+	}
+
+	g.log.Warn("⚠️ [POOL] Naming scheme ran out of unique names", "naming_scheme", g.IncusNamingScheme)
+	return "", false
+}
+
+// pooled reports whether name is waiting in the pool. Must be called with g.m held.
+func (g *InstanceGroup) pooled(name string) bool {
+	for _, n := range g.pool {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
+
+// createPooled creates one stopped instance, on the given cluster member if any, and adds it to
+// the pool. It reports whether refilling should go on.
+func (g *InstanceGroup) createPooled(ctx context.Context, name, member string) bool {
+	// Shares incus_create_concurrency with scale ups
+	select {
+	case g.createSlots <- struct{}{}:
+		defer func() { <-g.createSlots }()
+	case <-ctx.Done():
+		return false
+	}
+
+	opts := g.poolOptions(name)
+	opts.Target = member
+	err := g.Backend.CreateInstance(ctx, opts)
+	if err != nil {
+		if ctx.Err() == nil {
+			g.log.Error("❌ [POOL] Failed to create pooled VM", "vm_name", name, "error", err, "error_class", errorClass(err))
+		}
+		// Best effort, Incus may have created it before failing
+		if delErr := g.Backend.DeleteInstance(context.WithoutCancel(ctx), name); delErr != nil {
+			g.log.Warn("⚠️ [POOL] Failed to clean up pooled VM", "vm_name", name, "error", delErr)
+		}
+		return false
+	}
+
+	g.m.Lock()
+	g.pool = append(g.pool, name)
+	g.metrics.poolReady.Store(int64(len(g.pool)))
+	g.m.Unlock()
+
+	g.log.Info("🧊 [POOL] Pooled VM ready", "vm_name", name, "image", g.origin(), "member", member)
+	return true
+}
+
+// poolOptions describes a pooled instance. In snapshot recycle mode, its pristine state is
+// kept as a snapshot to return to.
+func (g *InstanceGroup) poolOptions(name string) incusprov.CreateOptions {
+	opts := incusprov.CreateOptions{
+		Name:     name,
+		Type:     g.IncusInstanceType,
+		CPU:      g.size.cpus,
+		Memory:   g.size.memory,
+		Size:     g.size.instanceType,
+		Image:    g.IncusImage,
+		Source:   g.source(),
+		DiskSize: g.IncusDiskSize,
+		Owner:    incusprov.Owner{Group: g.IncusGroup, PluginVersion: Version},
+		Config:   map[string]string{incusprov.ConfigKeyPool: "warm"},
+		Stopped:  true,
+	}
+	if g.IncusRecycle == recycleSnapshot {
+		opts.Snapshot = recycleSnapshotName
+	}
+	return opts
+}
+
+// claimPooled takes an instance out of the pool, if one is ready. Must be called with g.m held.
+func (g *InstanceGroup) claimPooled() (string, bool) {
+	if len(g.pool) == 0 {
+		return "", false
+	}
+
+	name := g.pool[0]
+	g.pool = g.pool[1:]
+	g.metrics.poolReady.Store(int64(len(g.pool)))
+	return name, true
+}
+
+// startPooled starts a claimed pooled instance with the settings of opts. If it does not
+// start, it is replaced by an instance created from scratch under the same name.
+func (g *InstanceGroup) startPooled(opts incusprov.CreateOptions) error {
+	err := g.Backend.StartInstance(g.ctx, opts)
+	if err == nil || g.ctx.Err() != nil {
+		return err
+	}
+
+	g.log.Warn("⚠️ [POOL] Pooled VM failed to start, creating it from the image instead",
+		"vm_name", opts.Name,
+		"error", err,
+		"error_class", errorClass(err))
+	if delErr := g.removeLeftover(opts.Name); delErr != nil {
+		return fmt.Errorf("%w (%w)", delErr, errLeftover)
+	}
+	return g.createInstance(opts)
+}
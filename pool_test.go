@@ -0,0 +1,220 @@
+package fleetingincus
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"fleeting-plugin-incus/incusprov"
+
+	"gitlab.com/gitlab-org/fleeting/fleeting/provider"
+)
+
+// waitForPool waits until the backend holds n pooled instances
+func waitForPool(t *testing.T, backend *fakeBackend, n int) []string {
+	t.Helper()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		pooled := backend.pooled()
+		if len(pooled) == n {
+			return pooled
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("warm pool holds %v, want %d instances", pooled, n)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestWarmPool(t *testing.T) {
+	backend := newFakeBackend()
+	g := newTestGroup(t, backend, t.TempDir(), func(g *InstanceGroup) {
+		g.IncusImage = "runner-base"
+		g.IncusWarmPoolSize = 2
+	})
+	defer shutdown(t, g)
+
+	pooled := waitForPool(t, backend, 2)
+
+	// Pooled instances are not reported before they are claimed
+	if reported := states(t, g); len(reported) != 0 {
+		t.Fatalf("pooled instances reported: %v", reported)
+	}
+
+	names := scaleUp(t, g, 3)
+	claimed := 0
+	for _, name := range names {
+		if name == pooled[0] || name == pooled[1] {
+			claimed++
+		}
+	}
+	if claimed != 2 {
+		t.Errorf("scale up used %d pooled instances, want 2", claimed)
+	}
+
+	backend.mu.Lock()
+	starts := backend.starts
+	backend.mu.Unlock()
+	if starts != 2 {
+		t.Errorf("started %d pooled instances, want 2", starts)
+	}
+
+	// The refiller restores the pool after the claim
+	waitForPool(t, backend, 2)
+	if n := backend.count(); n != 5 {
+		t.Errorf("backend has %d instances, want 3 running and 2 pooled", n)
+	}
+}
+
+func TestWarmPoolRebuildsOnImageChange(t *testing.T) {
+	backend := newFakeBackend()
+	backend.add("runner-old", testGroup, "Stopped")
+	backend.instances["runner-old"].info.Pool = true
+	backend.instances["runner-old"].info.Image = "runner-base-v1"
+
+	g := newTestGroup(t, backend, t.TempDir(), func(g *InstanceGroup) {
+		g.IncusImage = "runner-base-v2"
+		g.IncusWarmPoolSize = 1
+	})
+	defer shutdown(t, g)
+
+	deadline := time.Now().Add(5 * time.Second)
+	for backend.exists("runner-old") {
+		if time.Now().After(deadline) {
+			t.Fatal("pooled instance of the previous image was not deleted")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	pooled := waitForPool(t, backend, 1)
+	if opts := backend.options(pooled[0]); opts.Image != "runner-base-v2" || !opts.Stopped {
+		t.Errorf("pool rebuilt as %+v, want a stopped runner-base-v2 instance", opts)
+	}
+}
+
+func TestWarmPoolKeepsInstancesRecycledWhileListing(t *testing.T) {
+	backend := newFakeBackend()
+	g := newTestGroup(t, backend, t.TempDir(), func(g *InstanceGroup) {
+		g.IncusImage = "runner-base"
+		g.IncusWarmPoolSize = 1
+	})
+	defer shutdown(t, g)
+
+	waitForPool(t, backend, 1)
+
+	// A recycled instance is on its way back and arrives right after the refiller listed Incus
+	g.m.Lock()
+	g.IncusWarmPoolSize = 2
+	g.recycling = 1
+	g.m.Unlock()
+	backend.mu.Lock()
+	backend.listed = func() {
+		backend.add("runner-recycled", testGroup, "Stopped")
+		backend.mu.Lock()
+		backend.instances["runner-recycled"].info.Pool = true
+		backend.mu.Unlock()
+
+		g.m.Lock()
+		g.pool = append(g.pool, "runner-recycled")
+		g.recycling--
+		g.m.Unlock()
+	}
+	creates := backend.creates
+	backend.mu.Unlock()
+
+	g.refillPool(context.Background())
+
+	g.m.Lock()
+	pooled := g.pooled("runner-recycled")
+	g.m.Unlock()
+	if !pooled {
+		t.Error("recycled instance dropped from the pool")
+	}
+	backend.mu.Lock()
+	defer backend.mu.Unlock()
+	if backend.creates != creates {
+		t.Errorf("created %d pooled instances for a full pool, want 0", backend.creates-creates)
+	}
+}
+
+func TestWarmPoolRebuildsOnRepublishedImage(t *testing.T) {
+	backend := newFakeBackend()
+	backend.images = map[string]string{"runner-base": "f1"}
+	g := newTestGroup(t, backend, t.TempDir(), func(g *InstanceGroup) {
+		g.IncusImage = "runner-base"
+		g.IncusWarmPoolSize = 1
+	})
+	defer shutdown(t, g)
+
+	old := waitForPool(t, backend, 1)[0]
+
+	// incus publish --alias runner-base moves the alias to a new image
+	backend.mu.Lock()
+	backend.images["runner-base"] = "f2"
+	backend.mu.Unlock()
+	g.wakePool()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for backend.exists(old) {
+		if time.Now().After(deadline) {
+			t.Fatal("pooled instance of the previous image was not deleted")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	pooled := waitForPool(t, backend, 1)
+	if vm, _ := backend.GetInstance(context.Background(), pooled[0]); vm.BaseImage != "f2" {
+		t.Errorf("pool rebuilt from image %q, want f2", vm.BaseImage)
+	}
+}
+
+func TestWarmPoolFallsBackToCreate(t *testing.T) {
+	backend := newFakeBackend()
+	g := newTestGroup(t, backend, t.TempDir(), func(g *InstanceGroup) {
+		g.IncusWarmPoolSize = 1
+	})
+	defer shutdown(t, g)
+
+	pooled := waitForPool(t, backend, 1)
+	backend.mu.Lock()
+	backend.startErr = fmt.Errorf("simulated start failure: %w", incusprov.ErrTransient)
+	backend.mu.Unlock()
+
+	created, err := g.Increase(context.Background(), 1)
+	if err != nil || created != 1 {
+		t.Fatalf("Increase = %d, %v, want 1", created, err)
+	}
+	reported := waitForStates(t, g, provider.StateRunning)
+	if reported[pooled[0]] != provider.StateRunning {
+		t.Errorf("pooled instance %s reported %v, want running", pooled[0], reported)
+	}
+	if opts := backend.options(pooled[0]); opts.Stopped || opts.Config[incusprov.ConfigKeyPool] != "" {
+		t.Errorf("%s was not recreated from the image: %+v", pooled[0], opts)
+	}
+}
+
+func TestWarmPoolClaimRemovedBeforeStart(t *testing.T) {
+	backend := newFakeBackend()
+	g := newTestGroup(t, backend, t.TempDir(), func(g *InstanceGroup) {
+		g.IncusWarmPoolSize = 1
+		g.IncusCreateConcurrency = 1
+	})
+	defer shutdown(t, g)
+
+	pooled := waitForPool(t, backend, 1)
+
+	// Hold the only creation slot, so the claimed instance waits to be started
+	g.createSlots <- struct{}{}
+	if created, err := g.Increase(context.Background(), 1); err != nil || created != 1 {
+		t.Fatalf("Increase = %d, %v, want 1", created, err)
+	}
+	if _, err := g.Decrease(context.Background(), pooled); err != nil {
+		t.Fatalf("Decrease: %v", err)
+	}
+	<-g.createSlots
+
+	waitForStates(t, g, provider.StateDeleted)
+	if backend.exists(pooled[0]) {
+		t.Errorf("%s: claimed pooled instance left behind in Incus", pooled[0])
+	}
+}
@@ -0,0 +1,159 @@
+package fleetingincus
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strconv"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// Readiness probe types
+const (
+	probeExec      = "exec"       // Run a command inside the instance
+	probeCloudInit = "cloud-init" // Wait for cloud-init to finish
+	probeTCP       = "tcp"        // Dial a port on the instance from the plugin host
+	probeSSH       = "ssh"        // Complete an SSH handshake with the instance's key
+)
+
+// probeDialTimeout bounds a single tcp/ssh probe attempt
+const probeDialTimeout = 5 * time.Second
+
+// ProbeConfig configures one readiness probe in incus_readiness_probes
+type ProbeConfig struct {
+	Type     string   `json:"type"`      // exec, cloud-init, tcp or ssh
+	Command  []string `json:"command"`   // exec: command to run inside the instance
+	ExitCode *int     `json:"exit_code"` // exec: expected exit code (default: 0)
+	Port     int      `json:"port"`      // tcp, ssh: port to dial (default: 22)
+
+	anyExitCode bool // exec: any exit code passes, for the built-in default probe
+}
+
+// defaultProbes waits for systemd to finish booting, regardless of degraded units
+var defaultProbes = []ProbeConfig{
+	{Type: probeExec, Command: []string{"systemctl", "is-system-running", "--wait"}, anyExitCode: true},
+}
+
+// validateProbes checks the configured probes in Init
+func validateProbes(probes []ProbeConfig) error {
+	for i, p := range probes {
+		switch p.Type {
+		case probeExec:
+			if len(p.Command) == 0 {
+				return fmt.Errorf("incus_readiness_probes[%d]: exec probe requires a command", i)
+			}
+		case probeCloudInit, probeTCP, probeSSH:
+		default:
+			return fmt.Errorf("incus_readiness_probes[%d]: unknown probe type %q (exec, cloud-init, tcp, ssh)", i, p.Type)
+		}
+
+		if p.Port < 0 || p.Port > 65535 {
+			return fmt.Errorf("incus_readiness_probes[%d]: invalid port %d", i, p.Port)
+		}
+	}
+
+	return nil
+}
+
+// withoutSSHProbes returns probes without the ssh ones
+func withoutSSHProbes(probes []ProbeConfig) []ProbeConfig {
+	var filtered []ProbeConfig
+	for _, p := range probes {
+		if p.Type != probeSSH {
+			filtered = append(filtered, p)
+		}
+	}
+	return filtered
+}
+
+// probeCredentials is what the ssh probe authenticates with: the same user and key
+// fleeting will connect with
+type probeCredentials struct {
+	username string
+	key      []byte
+}
+
+// check runs the probe once against the named instance
+func (p ProbeConfig) check(ctx context.Context, b Backend, name string, creds probeCredentials) error {
+	switch p.Type {
+	case probeExec:
+		code, err := b.Exec(ctx, name, p.Command)
+		if err != nil {
+			return err
+		}
+		want := 0
+		if p.ExitCode != nil {
+			want = *p.ExitCode
+		}
+		if !p.anyExitCode && code != want {
+			return fmt.Errorf("command %v exited with %d, expected %d", p.Command, code, want)
+		}
+		return nil
+
+	case probeCloudInit:
+		code, err := b.Exec(ctx, name, []string{"cloud-init", "status", "--wait"})
+		if err != nil {
+			return err
+		}
+		// 2 means cloud-init finished with recoverable errors
+		if code != 0 && code != 2 {
+			return fmt.Errorf("cloud-init failed with exit code %d", code)
+		}
+		return nil
+
+	case probeTCP:
+		conn, err := p.dial(ctx, b, name)
+		if err != nil {
+			return err
+		}
+		return conn.Close()
+
+	case probeSSH:
+		signer, err := ssh.ParsePrivateKey(creds.key)
+		if err != nil {
+			return fmt.Errorf("invalid SSH key for probe: %w", err)
+		}
+		conn, err := p.dial(ctx, b, name)
+		if err != nil {
+			return err
+		}
+		defer conn.Close()
+
+		// The handshake itself must not outlive the probe either
+		conn.SetDeadline(time.Now().Add(probeDialTimeout))
+		sshConn, _, _, err := ssh.NewClientConn(conn, conn.RemoteAddr().String(), &ssh.ClientConfig{
+			User:            creds.username,
+			Auth:            []ssh.AuthMethod{ssh.PublicKeys(signer)},
+			HostKeyCallback: ssh.InsecureIgnoreHostKey(), // Fresh instances have unknown host keys
+		})
+		if err != nil {
+			return err
+		}
+		return sshConn.Close()
+	}
+
+	return fmt.Errorf("unknown probe type '%s'", p.Type)
+}
+
+// dial opens a TCP connection to the probe port of the instance
+func (p ProbeConfig) dial(ctx context.Context, b Backend, name string) (net.Conn, error) {
+	vm, err := b.GetInstance(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	if vm.Address == "" {
+		return nil, fmt.Errorf("no IP address for VM '%s' yet", name)
+	}
+
+	dialer := net.Dialer{Timeout: probeDialTimeout}
+	return dialer.DialContext(ctx, "tcp", net.JoinHostPort(vm.Address, strconv.Itoa(p.port())))
+}
+
+func (p ProbeConfig) port() int {
+	if p.Port == 0 {
+		return 22
+	}
+	return p.Port
+}
@@ -0,0 +1,153 @@
+package fleetingincus
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// probeTarget adds a running instance reachable on the loopback address
+func probeTarget(t *testing.T) (*fakeBackend, string) {
+	t.Helper()
+
+	backend := newFakeBackend()
+	backend.add("probed", testGroup, "Running")
+	backend.instances["probed"].info.Address = "127.0.0.1"
+	return backend, "probed"
+}
+
+// listen opens a loopback listener that serve handles connections of, and returns its port
+func listen(t *testing.T, serve func(conn net.Conn)) int {
+	t.Helper()
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { l.Close() })
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go serve(conn)
+		}
+	}()
+
+	return l.Addr().(*net.TCPAddr).Port
+}
+
+// closedPort returns a loopback port nothing listens on
+func closedPort(t *testing.T) int {
+	t.Helper()
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	port := l.Addr().(*net.TCPAddr).Port
+	l.Close()
+	return port
+}
+
+func TestExecProbe(t *testing.T) {
+	backend, name := probeTarget(t)
+	backend.exitCodes = map[string]int{"docker info": 1, "systemctl is-system-running --wait": 1}
+	three := 3
+
+	for _, tt := range []struct {
+		probe ProbeConfig
+		pass  bool
+	}{
+		// A configured probe expects 0 unless told otherwise
+		{ProbeConfig{Type: probeExec, Command: []string{"docker", "info"}}, false},
+		{ProbeConfig{Type: probeExec, Command: []string{"true"}}, true},
+		{ProbeConfig{Type: probeExec, Command: []string{"true"}, ExitCode: &three}, false},
+		// The built-in default tolerates degraded systems
+		{defaultProbes[0], true},
+	} {
+		err := tt.probe.check(context.Background(), backend, name, probeCredentials{})
+		if (err == nil) != tt.pass {
+			t.Errorf("%v: error %v, want pass %t", tt.probe.Command, err, tt.pass)
+		}
+	}
+}
+
+func TestTCPProbe(t *testing.T) {
+	backend, name := probeTarget(t)
+	open := listen(t, func(conn net.Conn) { conn.Close() })
+
+	probe := ProbeConfig{Type: probeTCP, Port: open}
+	if err := probe.check(context.Background(), backend, name, probeCredentials{}); err != nil {
+		t.Errorf("open port: %v", err)
+	}
+	probe.Port = closedPort(t)
+	if err := probe.check(context.Background(), backend, name, probeCredentials{}); err == nil {
+		t.Error("closed port passed")
+	}
+}
+
+func TestSSHProbe(t *testing.T) {
+	backend, name := probeTarget(t)
+
+	hostKey, _, err := generateKeyPair()
+	if err != nil {
+		t.Fatal(err)
+	}
+	hostSigner, err := ssh.ParsePrivateKey(hostKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	clientKey, authorized, err := generateKeyPair()
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherKey, _, err := generateKeyPair()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Accepts the instance key for root only
+	config := &ssh.ServerConfig{
+		PublicKeyCallback: func(meta ssh.ConnMetadata, key ssh.PublicKey) (*ssh.Permissions, error) {
+			if meta.User() == "root" && string(ssh.MarshalAuthorizedKey(key)) == authorized+"\n" {
+				return nil, nil
+			}
+			return nil, ssh.ErrNoAuth
+		},
+	}
+	config.AddHostKey(hostSigner)
+	port := listen(t, func(conn net.Conn) {
+		defer conn.Close()
+		if sshConn, chans, reqs, err := ssh.NewServerConn(conn, config); err == nil {
+			go ssh.DiscardRequests(reqs)
+			for ch := range chans {
+				ch.Reject(ssh.Prohibited, "probe only")
+			}
+			sshConn.Close()
+		}
+	})
+
+	probe := ProbeConfig{Type: probeSSH, Port: port}
+	for _, tt := range []struct {
+		creds probeCredentials
+		pass  bool
+	}{
+		{probeCredentials{username: "root", key: clientKey}, true},
+		{probeCredentials{username: "root", key: otherKey}, false},
+		{probeCredentials{username: "ubuntu", key: clientKey}, false},
+	} {
+		err := probe.check(context.Background(), backend, name, tt.creds)
+		if (err == nil) != tt.pass {
+			t.Errorf("%s: error %v, want pass %t", tt.creds.username, err, tt.pass)
+		}
+	}
+
+	probe.Port = closedPort(t)
+	if err := probe.check(context.Background(), backend, name, probeCredentials{username: "root", key: clientKey}); err == nil {
+		t.Error("closed port passed")
+	}
+}
@@ -0,0 +1,326 @@
+package fleetingincus
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"fleeting-plugin-incus/incusprov"
+
+	"gitlab.com/gitlab-org/fleeting/fleeting/provider"
+)
+
+// Creation attempts for transient Incus failures
+const createAttempts = 3
+
+// createRetryDelay is the base backoff between creation attempts (a variable so tests can shorten it)
+var createRetryDelay = 5 * time.Second
+
+// provisionRequest carries everything a background worker needs to create one instance
+type provisionRequest struct {
+	name           string
+	progress       string
+	image          string
+	source         string // Copy source instead of image, if configured
+	instanceType   string
+	size           string // As configured, for logs
+	cpus           int
+	memory         int64
+	sizeType       string // Instance type left to Incus, if cpus and memory are unknown
+	diskSize       string
+	startupTimeout int
+	owner          incusprov.Owner
+	ephemeralKeys  bool
+	username       string
+	staticKey      []byte
+	pooled         bool   // Claimed from the warm pool, only needs to be started
+	template       string // Snapshot template to clone, if prepared
+	member         string // Cluster member to create the instance on, if chosen by the plugin
+}
+
+// provision creates and probes one instance in the background. The instance is already
+// registered as StateCreating; Update reports its transition to Running, Timeout or Deleted.
+func (g *InstanceGroup) provision(req provisionRequest) {
+	defer g.wg.Done()
+	name := req.name
+	defer g.release(name)
+	defer g.breaker.settle(name)
+
+	// Bounded parallelism (incus_create_concurrency)
+	select {
+	case g.createSlots <- struct{}{}:
+		defer func() { <-g.createSlots }()
+	case <-g.ctx.Done():
+		g.log.Warn("🛑 [CREATE] Plugin shutting down, VM creation skipped", "vm_name", name)
+		g.failCreate(name)
+		return
+	}
+
+	// Removed by Decrease while still queued. A claimed pooled instance already exists in Incus
+	// and is no longer in the pool, so it is deleted; nothing else would clean it up.
+	g.m.Lock()
+	state := g.status[name]
+	if state != provider.StateCreating && !req.pooled {
+		g.status[name] = provider.StateDeleted
+		save(g.StateFilePath, g.status, g.members)
+	}
+	g.m.Unlock()
+	if state != provider.StateCreating {
+		g.log.Info("⏭️ [CREATE] VM removed before creation started", "vm_name", name, "warm_pool", req.pooled)
+		if req.pooled {
+			g.deleteInstance(name)
+		}
+		return
+	}
+
+	g.log.Info("🔨 [CREATE] Creating VM",
+		"vm_name", name,
+		"progress", req.progress,
+		"image", req.image,
+		"source", req.source,
+		"type", req.instanceType,
+		"size", req.size,
+		"disk_size", req.diskSize,
+		"warm_pool", req.pooled,
+		"template", req.template,
+		"member", req.member)
+
+	// Fresh key pair per instance unless static credentials are configured
+	publicKey := g.publicKey
+	privateKey := req.staticKey
+	var files []incusprov.File
+	if req.ephemeralKeys {
+		var keyErr error
+		privateKey, publicKey, keyErr = generateKeyPair()
+		if keyErr == nil {
+			keyErr = g.keys.save(name, privateKey)
+		}
+		if keyErr != nil {
+			g.log.Error("❌ [CREATE] SSH key setup failed", "vm_name", name, "error", keyErr)
+			g.failCreate(name)
+			return
+		}
+
+		// Authorized before probing, so an ssh probe can already use it
+		files = append(files, incusprov.File{
+			Path:    g.authorizedKeysPath(),
+			Content: []byte(publicKey + "\n"),
+			Mode:    0644, // World-readable, so sshd also accepts the root-owned file for non-root users
+			Append:  true,
+		})
+	}
+
+	// Render per-instance cloud-init config
+	cloudInitConfig, renderErr := g.cloudInit.render(cloudInitVars{
+		Name:      name,
+		Group:     req.owner.Group,
+		PublicKey: publicKey,
+	})
+	if renderErr != nil {
+		g.log.Error("❌ [CREATE] cloud-init rendering failed", "vm_name", name, "error", renderErr)
+		g.failCreate(name)
+		return
+	}
+
+	// Create the VM, then wait for it to become ready
+	opts := incusprov.CreateOptions{
+		Name:     name,
+		Type:     req.instanceType,
+		CPU:      req.cpus,
+		Memory:   req.memory,
+		Size:     req.sizeType,
+		Image:    req.image,
+		Source:   req.source,
+		DiskSize: req.diskSize,
+		Owner:    req.owner,
+		Config:   cloudInitConfig,
+		Target:   req.member,
+	}
+	var createErr error
+	var setup []string
+	switch {
+	case req.pooled:
+		createErr = g.startPooled(opts)
+	case req.template != "":
+		// A clone resumes where the template was; cloud-init does not run again
+		var cloned bool
+		cloned, createErr = g.cloneTemplate(req.template, opts)
+		if cloned {
+			setup = []string{"sh", "-c", identityScript, "identity", name}
+		}
+	default:
+		createErr = g.createInstance(opts)
+	}
+	if createErr == nil {
+		createErr = g.waitReady(g.ctx, name, setup, files, probeCredentials{username: req.username, key: privateKey}, req.startupTimeout)
+	}
+	var timeoutErr *startupTimeoutError
+	if errors.As(createErr, &timeoutErr) {
+		g.log.Error("⏰ [CREATE] VM startup timed out",
+			"vm_name", name,
+			"progress", req.progress,
+			"timeout", timeoutErr.Timeout,
+			"retention", g.IncusFailureRetention,
+			"error", createErr,
+			"total_timed_out", g.metrics.timedOut.Load()+1)
+
+		// Never-ready instances usually mean a broken image
+		g.creationFailed(name)
+		g.handleStartupTimeout(name)
+		return
+	}
+	if createErr != nil && g.ctx.Err() != nil {
+		// Interrupted by Shutdown; the half-created instance is deleted by Update after restart
+		g.log.Warn("🛑 [CREATE] VM creation interrupted by shutdown", "vm_name", name, "error", createErr)
+
+		g.m.Lock()
+		g.status[name] = provider.StateDeleting
+		save(g.StateFilePath, g.status, g.members)
+		g.m.Unlock()
+		return
+	}
+	if createErr != nil {
+		g.log.Error("❌ [CREATE] VM creation failed",
+			"vm_name", name,
+			"progress", req.progress,
+			"error_class", errorClass(createErr),
+			"error", createErr,
+			"total_failed", g.metrics.failed.Load()+1)
+
+		// An instance removed behind our back or a name clash says nothing about the next creation
+		if !incusprov.IsRetryable(createErr) && !errors.Is(createErr, incusprov.ErrNotFound) && !errors.Is(createErr, incusprov.ErrAlreadyExists) {
+			g.creationFailed(name)
+		}
+		if errors.Is(createErr, errLeftover) {
+			// Update retries the deletion; reported as deleted, the instance would leak
+			g.metrics.failed.Add(1)
+			g.m.Lock()
+			g.status[name] = provider.StateDeleting
+			save(g.StateFilePath, g.status, g.members)
+			g.m.Unlock()
+			return
+		}
+		g.failCreate(name)
+		return
+	}
+
+	// Mark VM as running, unless it was removed while we were creating it
+	g.m.Lock()
+	state = g.status[name]
+	if state == provider.StateCreating {
+		g.status[name] = provider.StateRunning
+		save(g.StateFilePath, g.status, g.members)
+	}
+	member := g.members[name]
+	g.m.Unlock()
+
+	if state != provider.StateCreating {
+		g.log.Warn("⚠️ [CREATE] VM was removed during creation, deleting it", "vm_name", name, "state", state)
+		g.deleteInstance(name)
+		return
+	}
+
+	if g.breaker.success() {
+		g.metrics.breakerOpen.Store(false)
+		g.log.Info("✅ [BREAKER] Creation circuit closed", "vm_name", name)
+	}
+
+	g.metrics.created.Add(1)
+	g.log.Info("✅ [CREATE] VM creation completed",
+		"vm_name", name,
+		"progress", req.progress,
+		"member", member,
+		"total_created", g.metrics.created.Load())
+}
+
+// errLeftover marks a failed creation whose half-created instance could not be removed
+var errLeftover = errors.New("half-created instance left behind")
+
+// createInstance creates an instance, retrying transient failures. A failed attempt may leave
+// a half-created instance behind, which is removed before retrying or giving up; if that fails,
+// the error wraps errLeftover.
+func (g *InstanceGroup) createInstance(opts incusprov.CreateOptions) (err error) {
+	for attempt := 1; ; attempt++ {
+		err = g.Backend.CreateInstance(g.ctx, opts)
+		if err == nil || g.ctx.Err() != nil {
+			// Interrupted instances are cleaned up after restart
+			return err
+		}
+
+		// The name is taken by an instance this attempt did not create, possibly a foreign one
+		if errors.Is(err, incusprov.ErrAlreadyExists) {
+			return err
+		}
+
+		if delErr := g.removeLeftover(opts.Name); delErr != nil {
+			g.log.Warn("⚠️ [CREATE] Failed to remove VM after failed creation", "vm_name", opts.Name, "error", delErr)
+			return fmt.Errorf("%w (%w)", err, errLeftover)
+		}
+
+		// Missing images and exhausted quotas fail the same way on every attempt
+		if !incusprov.IsRetryable(err) || attempt == createAttempts {
+			return err
+		}
+
+		delay := backoff(createRetryDelay, attempt)
+		g.log.Warn("🔁 [CREATE] VM creation failed, retrying",
+			"vm_name", opts.Name,
+			"attempt", attempt,
+			"retry_in", delay,
+			"error_class", errorClass(err),
+			"error", err)
+		select {
+		case <-time.After(delay):
+		case <-g.ctx.Done():
+			return err
+		}
+	}
+}
+
+// removeLeftover deletes what a failed creation left behind, but only an instance that carries
+// our group tag; anything else under that name was not created by us
+func (g *InstanceGroup) removeLeftover(name string) error {
+	vm, err := g.Backend.GetInstance(g.ctx, name)
+	if errors.Is(err, incusprov.ErrNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if vm.Group != g.IncusGroup {
+		g.log.Warn("⚠️ [CREATE] Instance of that name is not ours, leaving it alone", "vm_name", name, "group", vm.Group)
+		return nil
+	}
+
+	err = g.Backend.DeleteInstance(g.ctx, name)
+	if errors.Is(err, incusprov.ErrNotFound) {
+		return nil
+	}
+	return err
+}
+
+// creationFailed counts a creation failure that retrying did not fix towards the circuit breaker
+func (g *InstanceGroup) creationFailed(name string) {
+	if !g.breaker.failure(name, time.Now()) {
+		return
+	}
+
+	g.metrics.breakerOpen.Store(true)
+	g.metrics.breakerTrips.Add(1)
+	g.log.Error("⛔ [BREAKER] Creation circuit opened, pausing scale ups",
+		"vm_name", name,
+		"consecutive_failures", g.IncusBreakerThreshold,
+		"cooldown", g.breaker.coolDown)
+}
+
+// failCreate reports an instance that could not be created as deleted, so fleeting forgets it
+func (g *InstanceGroup) failCreate(name string) {
+	g.metrics.failed.Add(1)
+
+	g.m.Lock()
+	g.status[name] = provider.StateDeleted
+	save(g.StateFilePath, g.status, g.members)
+	g.m.Unlock()
+
+	g.discardKey(name)
+}
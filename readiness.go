@@ -0,0 +1,121 @@
+package fleetingincus
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"fleeting-plugin-incus/incusprov"
+)
+
+// probeInterval is the pause between readiness checks (a variable so tests can shorten it)
+var probeInterval = 2 * time.Second
+
+// startupTimeoutError is returned when an instance was created but did not pass its
+// readiness probes within the startup timeout. The instance still exists in Incus.
+type startupTimeoutError struct {
+	Name    string
+	Timeout time.Duration
+	Err     error // Last probe or file push failure, if any
+}
+
+func (e *startupTimeoutError) Error() string {
+	if e.Err == nil {
+		return fmt.Sprintf("⏰ [CREATE] VM '%s' not ready within %s", e.Name, e.Timeout)
+	}
+	return fmt.Sprintf("⏰ [CREATE] VM '%s' not ready within %s: %v", e.Name, e.Timeout, e.Err)
+}
+
+func (e *startupTimeoutError) Unwrap() error {
+	return e.Err
+}
+
+// waitReady runs setup (if any) in a freshly started instance, pushes files into it and waits
+// until all readiness probes pass, or the startup timeout expires
+func (g *InstanceGroup) waitReady(ctx context.Context, name string, setup []string, files []incusprov.File, creds probeCredentials, startupTimeout int) (err error) {
+	probes := g.IncusReadinessProbes
+	if len(probes) == 0 {
+		probes = defaultProbes
+	}
+	if creds.key == nil {
+		// Instances without a key of their own (the snapshot template) cannot pass ssh probes
+		probes = withoutSSHProbes(probes)
+	}
+	setupDone := len(setup) == 0
+	filesPushed := len(files) == 0
+
+	// Wait for system to be ready
+	timeout := time.Duration(startupTimeout) * time.Second
+	deadline := time.Now().Add(timeout)
+	maxRetries := max(int(timeout/probeInterval), 1) // Check every probeInterval, at least once
+	for retry := 1; ; retry++ {
+		if retry > maxRetries || time.Now().After(deadline) {
+			return &startupTimeoutError{Name: name, Timeout: timeout, Err: err}
+		}
+
+		select {
+		case <-time.After(probeInterval):
+		case <-ctx.Done():
+			return fmt.Errorf("🛑 [CREATE] VM '%s' creation cancelled: %w", name, ctx.Err())
+		}
+
+		// Setup and files go in as soon as the instance accepts them (VM agent up), before probing
+		if !setupDone {
+			if err = g.runSetup(ctx, name, setup); errors.Is(err, incusprov.ErrNotFound) {
+				return err
+			} else if err != nil {
+				continue
+			}
+			setupDone = true
+		}
+		if !filesPushed {
+			if err = g.pushFiles(ctx, name, files); errors.Is(err, incusprov.ErrNotFound) {
+				// Removed behind our back, no point in waiting for it
+				return err
+			} else if err != nil {
+				continue
+			}
+			filesPushed = true
+		}
+
+		// All probes must pass in the same round
+		ready := true
+		for _, probe := range probes {
+			if err = probe.check(ctx, g.Backend, name, creds); errors.Is(err, incusprov.ErrNotFound) {
+				return err
+			} else if err != nil {
+				err = fmt.Errorf("⚠️ [CREATE] %s probe failed for VM '%s' (retry %d/%d): %w", probe.Type, name, retry, maxRetries, err)
+				ready = false
+				break
+			}
+		}
+		if !ready {
+			continue
+		}
+
+		// System is ready
+		return nil
+	}
+}
+
+// runSetup runs a setup command in an instance; it fails unless the command exits with 0
+func (g *InstanceGroup) runSetup(ctx context.Context, name string, command []string) error {
+	code, err := g.Backend.Exec(ctx, name, command)
+	if err != nil {
+		return err
+	}
+	if code != 0 {
+		return fmt.Errorf("⚠️ [CREATE] setup in VM '%s' exited with %d", name, code)
+	}
+	return nil
+}
+
+func (g *InstanceGroup) pushFiles(ctx context.Context, name string, files []incusprov.File) error {
+	for _, f := range files {
+		if err := g.Backend.PushFile(ctx, name, f); err != nil {
+			return err
+		}
+	}
+	return nil
+}
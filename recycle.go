@@ -0,0 +1,100 @@
+package fleetingincus
+
+import (
+	"errors"
+
+	"fleeting-plugin-incus/incusprov"
+
+	"gitlab.com/gitlab-org/fleeting/fleeting/provider"
+)
+
+// Recycle modes for instances removed by Decrease
+const (
+	recycleOff      = "off"      // Delete them
+	recycleSnapshot = "snapshot" // Restore the snapshot taken when they were pooled, rebuild if there is none
+	recycleRebuild  = "rebuild"  // Rebuild them from incus_image
+)
+
+// recycleSnapshotName is the snapshot pooled instances get right after creation in snapshot mode
+const recycleSnapshotName = "clean"
+
+// queueRecycle marks a running instance as deleting and hands it to a background worker that
+// returns it to the warm pool. Must be called with g.m held.
+func (g *InstanceGroup) queueRecycle(name string) {
+	g.status[name] = provider.StateDeleting
+	g.busy[name] = true
+	g.wg.Add(1)
+	go g.recycle(name)
+}
+
+// recycle resets an instance and returns it to the warm pool under a fresh name, so fleeting
+// never sees the old name again. It falls back to deletion if the pool is full or recycling fails.
+func (g *InstanceGroup) recycle(name string) {
+	defer g.wg.Done()
+	defer g.release(name)
+
+	// Bounded parallelism (incus_delete_concurrency)
+	select {
+	case g.deleteSlots <- struct{}{}:
+		defer func() { <-g.deleteSlots }()
+	case <-g.ctx.Done():
+		g.log.Warn("🛑 [RECYCLE] Plugin shutting down, VM deletion postponed", "vm_name", name)
+		return
+	}
+
+	g.m.Lock()
+	full := len(g.pool)+g.recycling >= g.IncusWarmPoolSize
+	if !full {
+		g.recycling++
+	}
+	g.m.Unlock()
+	if full {
+		g.log.Info("🗑️ [RECYCLE] Warm pool is full, deleting VM instead", "vm_name", name)
+		g.deleteInstance(name)
+		return
+	}
+	defer func() {
+		g.m.Lock()
+		g.recycling--
+		g.m.Unlock()
+	}()
+
+	newName, ok := g.poolName(nil)
+	if !ok {
+		g.deleteInstance(name)
+		return
+	}
+
+	g.log.Info("♻️ [RECYCLE] Recycling VM", "vm_name", name, "new_name", newName, "mode", g.IncusRecycle)
+	opts := g.poolOptions(newName)
+	if g.IncusRecycle == recycleRebuild {
+		opts.Snapshot = ""
+	}
+	err := g.Backend.RecycleInstance(g.ctx, name, opts)
+	if err != nil {
+		if errors.Is(err, incusprov.ErrNotRecyclable) {
+			g.log.Info("🗑️ [RECYCLE] VM cannot be recycled, deleting it", "vm_name", name, "reason", err)
+		} else {
+			g.log.Warn("⚠️ [RECYCLE] VM recycling failed, deleting it", "vm_name", name, "error_class", errorClass(err), "error", err)
+		}
+		g.deleteInstance(name)
+		return
+	}
+
+	g.m.Lock()
+	g.status[name] = provider.StateDeleted
+	save(g.StateFilePath, g.status, g.members)
+	// The refiller may have found it under its new name already
+	if !g.pooled(newName) {
+		g.pool = append(g.pool, newName)
+	}
+	g.metrics.poolReady.Store(int64(len(g.pool)))
+	g.m.Unlock()
+	g.discardKey(name)
+	g.metrics.recycled.Add(1)
+
+	g.log.Info("✅ [RECYCLE] VM returned to the warm pool",
+		"vm_name", name,
+		"new_name", newName,
+		"total_recycled", g.metrics.recycled.Load())
+}
@@ -0,0 +1,114 @@
+package fleetingincus
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"fleeting-plugin-incus/incusprov"
+
+	"github.com/hashicorp/go-hclog"
+	"gitlab.com/gitlab-org/fleeting/fleeting/provider"
+)
+
+// scaleUpFromPool claims one pooled instance while keeping the refiller from replacing it
+func scaleUpFromPool(t *testing.T, g *InstanceGroup, backend *fakeBackend) string {
+	t.Helper()
+
+	waitForPool(t, backend, 1)
+	backend.mu.Lock()
+	backend.createErr = fmt.Errorf("simulated quota: %w", incusprov.ErrQuotaExceeded)
+	backend.mu.Unlock()
+
+	return scaleUp(t, g, 1)[0]
+}
+
+func TestRecycle(t *testing.T) {
+	for mode, want := range map[string]string{recycleSnapshot: "restores", recycleRebuild: "rebuilds"} {
+		t.Run(mode, func(t *testing.T) {
+			backend := newFakeBackend()
+			g := newTestGroup(t, backend, t.TempDir(), func(g *InstanceGroup) {
+				g.IncusWarmPoolSize = 1
+				g.IncusRecycle = mode
+			})
+			defer shutdown(t, g)
+
+			name := scaleUpFromPool(t, g, backend)
+			if _, err := g.Decrease(context.Background(), []string{name}); err != nil {
+				t.Fatalf("Decrease: %v", err)
+			}
+
+			// The old name is gone for good, the instance waits in the pool under a new one
+			if state := waitForStates(t, g, provider.StateDeleted)[name]; state != provider.StateDeleted {
+				t.Fatalf("%s: state %s, want deleted", name, state)
+			}
+			pooled := waitForPool(t, backend, 1)
+			if pooled[0] == name || backend.exists(name) {
+				t.Errorf("recycled instance kept its name %s", name)
+			}
+			if _, err := os.Stat(g.keys.path(name)); !os.IsNotExist(err) {
+				t.Errorf("%s: SSH key was not removed", name)
+			}
+
+			backend.mu.Lock()
+			counts := map[string]int{"restores": backend.restores, "rebuilds": backend.rebuilds}
+			backend.mu.Unlock()
+			if counts[want] != 1 {
+				t.Errorf("recycling did %v, want one of %s", counts, want)
+			}
+			if got := g.metrics.deleted.Load(); got != 0 {
+				t.Errorf("deleted metric %d, want 0", got)
+			}
+			if got := g.metrics.recycled.Load(); got != 1 {
+				t.Errorf("recycled metric %d, want 1", got)
+			}
+
+			// The recycled instance serves the next scale up
+			backend.mu.Lock()
+			backend.createErr = nil
+			backend.mu.Unlock()
+			if names := scaleUp(t, g, 1); len(names) != 1 || names[0] != pooled[0] {
+				t.Errorf("scale up did not use the recycled instance %s: %v", pooled[0], names)
+			}
+		})
+	}
+}
+
+func TestRecycleFullPool(t *testing.T) {
+	backend := newFakeBackend()
+	g := newTestGroup(t, backend, t.TempDir(), func(g *InstanceGroup) {
+		g.IncusWarmPoolSize = 1
+		g.IncusRecycle = recycleSnapshot
+	})
+	defer shutdown(t, g)
+
+	// Refilled right after the claim, no room for the recycled instance
+	name := scaleUp(t, g, 1)[0]
+	waitForPool(t, backend, 1)
+	if _, err := g.Decrease(context.Background(), []string{name}); err != nil {
+		t.Fatalf("Decrease: %v", err)
+	}
+
+	waitForStates(t, g, provider.StateDeleted)
+	if backend.exists(name) || len(backend.pooled()) != 1 {
+		t.Errorf("instance was not deleted: pool %v", backend.pooled())
+	}
+	if got := g.metrics.deleted.Load(); got != 1 {
+		t.Errorf("deleted metric %d, want 1", got)
+	}
+}
+
+func TestRecycleRequiresPool(t *testing.T) {
+	g := &InstanceGroup{
+		IncusGroup:    testGroup,
+		IncusRecycle:  recycleSnapshot,
+		StateFilePath: filepath.Join(t.TempDir(), "state.json"),
+		Backend:       newFakeBackend(),
+	}
+	if _, err := g.Init(context.Background(), hclog.NewNullLogger(), provider.Settings{}); err == nil {
+		shutdown(t, g)
+		t.Fatal("Init accepted incus_recycle without a warm pool")
+	}
+}
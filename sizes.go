@@ -0,0 +1,89 @@
+package fleetingincus
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/lxc/incus/shared/units"
+)
+
+// minDiskSize is the smallest root disk a CI instance gets by with
+const minDiskSize = 10 << 30
+
+// instanceSizePattern matches Incus' own instance type shorthand: cN-mM is N CPUs and M GiB of memory
+var instanceSizePattern = regexp.MustCompile(`^c(\d+)-m(\d+(?:\.\d+)?)$`)
+
+// shorthandPrefix matches sizes meant as cN-mM shorthand, so typos in them fail instead of being
+// passed on to Incus
+var shorthandPrefix = regexp.MustCompile(`^c\d+(-|m|$)`)
+
+// instanceTypePattern matches other instance type names Incus resolves itself, e.g. "c5.9xlarge",
+// "gce:n1-standard-1" or "azure:Standard_A1_v2"
+var instanceTypePattern = regexp.MustCompile(`^([a-z]+:)?[A-Za-z][A-Za-z0-9_.-]*$`)
+
+// awsInstanceSizes maps AWS instance type names onto CPUs and GiB of memory, as Incus does
+var awsInstanceSizes = map[string]struct {
+	cpus   int
+	memory float64
+}{
+	"t2.nano": {1, 0.5}, "t2.micro": {1, 1}, "t2.small": {1, 2}, "t2.medium": {2, 4},
+	"t2.large": {2, 8}, "t2.xlarge": {4, 16}, "t2.2xlarge": {8, 32},
+	"t3.nano": {2, 0.5}, "t3.micro": {2, 1}, "t3.small": {2, 2}, "t3.medium": {2, 4},
+	"t3.large": {2, 8}, "t3.xlarge": {4, 16}, "t3.2xlarge": {8, 32},
+	"m5.large": {2, 8}, "m5.xlarge": {4, 16}, "m5.2xlarge": {8, 32}, "m5.4xlarge": {16, 64},
+	"c5.large": {2, 4}, "c5.xlarge": {4, 8}, "c5.2xlarge": {8, 16}, "c5.4xlarge": {16, 32},
+	"r5.large": {2, 16}, "r5.xlarge": {4, 32}, "r5.2xlarge": {8, 64}, "r5.4xlarge": {16, 128},
+}
+
+// instanceResources is what one instance is allocated on the host. Zero fields are unknown.
+type instanceResources struct {
+	cpus         int
+	memory       int64  // Bytes
+	disk         int64  // Bytes
+	instanceType string // Instance type left to Incus, for sizes without known CPUs and memory
+}
+
+// parseInstanceSize returns CPUs and memory (in bytes) of an instance size like "c2-m4" or "t3.small".
+// Other instance type names Incus knows are returned as instanceType, with CPUs and memory unknown.
+func parseInstanceSize(size string) (cpus int, memory int64, instanceType string, err error) {
+	if aws, ok := awsInstanceSizes[strings.TrimPrefix(size, "aws:")]; ok {
+		return aws.cpus, int64(aws.memory * (1 << 30)), "", nil
+	}
+
+	m := instanceSizePattern.FindStringSubmatch(size)
+	if m == nil {
+		if !shorthandPrefix.MatchString(size) && instanceTypePattern.MatchString(size) {
+			return 0, 0, size, nil
+		}
+		return 0, 0, "", fmt.Errorf("unsupported instance size %q, expected cN-mM (N CPUs, M GiB memory) or an Incus instance type like t3.small", size)
+	}
+
+	cpus, err = strconv.Atoi(m[1])
+	if err != nil || cpus == 0 {
+		return 0, 0, "", fmt.Errorf("invalid CPU count in instance size %q", size)
+	}
+	gib, err := strconv.ParseFloat(m[2], 64)
+	if err != nil || gib == 0 {
+		return 0, 0, "", fmt.Errorf("invalid memory in instance size %q", size)
+	}
+
+	return cpus, int64(gib * (1 << 30)), "", nil
+}
+
+// parseDiskSize returns a disk size like "100GiB" in bytes; an empty size is 0
+func parseDiskSize(size string) (int64, error) {
+	if size == "" {
+		return 0, nil
+	}
+
+	bytes, err := units.ParseByteSizeString(size)
+	if err != nil {
+		return 0, fmt.Errorf("invalid disk size %q, expected a number with a unit like 50GiB", size)
+	}
+	if bytes < minDiskSize {
+		return 0, fmt.Errorf("disk size %q is below the minimum of %s", size, units.GetByteSizeStringIEC(minDiskSize, 0))
+	}
+	return bytes, nil
+}
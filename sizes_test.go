@@ -0,0 +1,58 @@
+package fleetingincus
+
+import "testing"
+
+func TestParseInstanceSize(t *testing.T) {
+	tests := []struct {
+		size   string
+		cpus   int
+		memory int64
+	}{
+		{"c1-m2", 1, 2 << 30},
+		{"c16-m64", 16, 64 << 30},
+		{"c2-m0.5", 2, 512 << 20},
+		{"t2.micro", 1, 1 << 30},
+		{"aws:t3.nano", 2, 512 << 20},
+		{"m5.xlarge", 4, 16 << 30},
+	}
+
+	for _, tt := range tests {
+		cpus, memory, instanceType, err := parseInstanceSize(tt.size)
+		if err != nil || instanceType != "" {
+			t.Errorf("parseInstanceSize(%q): %q, %v", tt.size, instanceType, err)
+			continue
+		}
+		if cpus != tt.cpus || memory != tt.memory {
+			t.Errorf("parseInstanceSize(%q) = %d CPUs, %d bytes, want %d CPUs, %d bytes", tt.size, cpus, memory, tt.cpus, tt.memory)
+		}
+	}
+
+	// Other instance types are left to Incus
+	for _, size := range []string{"c5.9xlarge", "m4.large", "t3a.medium", "gce:n1-standard-1", "azure:Standard_A1_v2"} {
+		cpus, memory, instanceType, err := parseInstanceSize(size)
+		if err != nil || instanceType != size || cpus != 0 || memory != 0 {
+			t.Errorf("parseInstanceSize(%q) = %d CPUs, %d bytes, %q, %v, want it left to Incus", size, cpus, memory, instanceType, err)
+		}
+	}
+
+	for _, size := range []string{"", "c0-m2", "c2-m0", "c2", "c2m4", "2-4", "c2-m4GiB", "t2 micro", "aws:"} {
+		if _, _, _, err := parseInstanceSize(size); err == nil {
+			t.Errorf("parseInstanceSize(%q) succeeded, want an error", size)
+		}
+	}
+}
+
+func TestParseDiskSize(t *testing.T) {
+	for size, want := range map[string]int64{"": 0, "10GiB": 10 << 30, "100GB": 100e9, "1TiB": 1 << 40, "20480MiB": 20 << 30} {
+		got, err := parseDiskSize(size)
+		if err != nil || got != want {
+			t.Errorf("parseDiskSize(%q) = %d, %v, want %d", size, got, err, want)
+		}
+	}
+
+	for _, size := range []string{"lots", "10GB ", "1.5TiB", "10G", "5GiB"} {
+		if _, err := parseDiskSize(size); err == nil {
+			t.Errorf("parseDiskSize(%q) succeeded, want an error", size)
+		}
+	}
+}
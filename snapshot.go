@@ -0,0 +1,179 @@
+package fleetingincus
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"fleeting-plugin-incus/incusprov"
+)
+
+// templateSnapshot is the stateful snapshot of the template VM that clones are restored from
+const templateSnapshot = "ready"
+
+// templateRetryInterval is the pause before preparing the template again after a failure
+// (a variable so tests can shorten it)
+var templateRetryInterval = time.Minute
+
+// invalidNameChars are characters Incus does not accept in instance names
+var invalidNameChars = regexp.MustCompile(`[^a-z0-9-]+`)
+
+// identityScript gives a clone restored from the template its own identity: hostname,
+// machine ID (which also seeds the DHCP client ID), SSH host keys and a fresh DHCP lease.
+// The instance name is passed as $1.
+const identityScript = `set -e
+hostnamectl set-hostname "$1" 2>/dev/null || hostname "$1"
+rm -f /etc/machine-id /var/lib/dbus/machine-id
+systemd-machine-id-setup >/dev/null 2>&1 || dbus-uuidgen --ensure=/etc/machine-id
+if [ -d /etc/ssh ]; then
+	rm -f /etc/ssh/ssh_host_*
+	ssh-keygen -A >/dev/null
+	systemctl try-restart ssh.service sshd.service 2>/dev/null || true
+fi
+networkctl renew 2>/dev/null || networkctl reconfigure --all 2>/dev/null || true
+`
+
+// templateName returns the name of the snapshot template VM of this group
+func (g *InstanceGroup) templateName() string {
+	name := "fleeting-template-" + strings.Trim(invalidNameChars.ReplaceAllString(strings.ToLower(g.IncusGroup), "-"), "-")
+	return strings.TrimRight(name[:min(len(name), 63)], "-")
+}
+
+// runTemplate prepares the snapshot template in the background, retrying until it is ready
+// or the plugin shuts down. Until then, instances are created from the image.
+func (g *InstanceGroup) runTemplate() {
+	defer g.wg.Done()
+
+	for {
+		err := g.prepareTemplate(g.ctx)
+		if err == nil || g.ctx.Err() != nil {
+			return
+		}
+		g.log.Error("❌ [SNAPSHOT] Template preparation failed, creating instances from the image",
+			"vm_name", g.templateName(),
+			"error", err,
+			"error_class", errorClass(err),
+			"retry_in", templateRetryInterval)
+
+		select {
+		case <-g.ctx.Done():
+			return
+		case <-time.After(templateRetryInterval):
+		}
+	}
+}
+
+// prepareTemplate makes sure the template VM of the configured image exists with its stateful
+// snapshot, then enables cloning. A template of another image is rebuilt.
+func (g *InstanceGroup) prepareTemplate(ctx context.Context) error {
+	name := g.templateName()
+
+	vms, err := g.Backend.ListInstances(ctx)
+	if err != nil {
+		return err
+	}
+
+	// The template is only stopped once its snapshot is taken
+	if vm, exists := vms[name]; exists {
+		fingerprint := g.imageFingerprint(ctx)
+		if !g.outdated(vm, fingerprint) && vm.Status == "Stopped" {
+			g.enableTemplate(name, false)
+			return nil
+		}
+
+		g.log.Info("♻️ [SNAPSHOT] Rebuilding template VM",
+			"vm_name", name,
+			"image", vm.Image,
+			"base_image", vm.BaseImage,
+			"status", vm.Status,
+			"want_image", g.origin(),
+			"want_base_image", fingerprint)
+		if err = g.Backend.DeleteInstance(ctx, name); err != nil {
+			return err
+		}
+	}
+
+	g.log.Info("📸 [SNAPSHOT] Preparing template VM", "vm_name", name, "image", g.origin())
+
+	owner := incusprov.Owner{Group: g.IncusGroup, PluginVersion: Version}
+	cloudInitConfig, err := g.cloudInit.render(cloudInitVars{
+		Name:      name,
+		Group:     owner.Group,
+		PublicKey: g.publicKey,
+	})
+	if err != nil {
+		return err
+	}
+	config := map[string]string{"migration.stateful": "true"}
+	for key, value := range cloudInitConfig {
+		config[key] = value
+	}
+
+	err = g.Backend.CreateInstance(ctx, incusprov.CreateOptions{
+		Name:     name,
+		Type:     incusprov.TypeVM,
+		CPU:      g.size.cpus,
+		Memory:   g.size.memory,
+		Size:     g.size.instanceType,
+		Image:    g.IncusImage,
+		Source:   g.source(),
+		DiskSize: g.IncusDiskSize,
+		Owner:    owner,
+		Config:   config,
+	})
+	if err == nil {
+		err = g.waitReady(ctx, name, nil, nil, probeCredentials{}, g.IncusStartupTimeout)
+	}
+	if err == nil {
+		err = g.Backend.SnapshotInstance(ctx, name, templateSnapshot)
+	}
+	if err != nil {
+		// Best effort, the next attempt starts from scratch anyway
+		if delErr := g.Backend.DeleteInstance(context.WithoutCancel(ctx), name); delErr != nil {
+			g.log.Warn("⚠️ [SNAPSHOT] Failed to clean up template VM", "vm_name", name, "error", delErr)
+		}
+		return err
+	}
+
+	g.enableTemplate(name, true)
+	return nil
+}
+
+// enableTemplate lets scale ups clone the template
+func (g *InstanceGroup) enableTemplate(name string, built bool) {
+	g.m.Lock()
+	g.template = name
+	g.m.Unlock()
+
+	g.log.Info("✅ [SNAPSHOT] Template VM ready, new instances are restored from its snapshot",
+		"vm_name", name,
+		"snapshot", templateSnapshot,
+		"built", built)
+}
+
+// cloneTemplate creates an instance by copying the template and restoring its stateful
+// snapshot. If that fails, the instance is created from the image instead; cloned reports
+// which of both happened.
+func (g *InstanceGroup) cloneTemplate(template string, opts incusprov.CreateOptions) (cloned bool, err error) {
+	clone := opts
+	clone.Source = template
+	clone.Restore = templateSnapshot
+
+	err = g.Backend.CreateInstance(g.ctx, clone)
+	if err == nil || g.ctx.Err() != nil || errors.Is(err, incusprov.ErrAlreadyExists) {
+		return err == nil, err
+	}
+
+	g.log.Warn("⚠️ [SNAPSHOT] Restoring from the template failed, creating the VM from the image instead",
+		"vm_name", opts.Name,
+		"template", template,
+		"error", err,
+		"error_class", errorClass(err))
+	if delErr := g.removeLeftover(opts.Name); delErr != nil {
+		return false, fmt.Errorf("%w (%w)", delErr, errLeftover)
+	}
+	return false, g.createInstance(opts)
+}
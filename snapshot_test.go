@@ -0,0 +1,145 @@
+package fleetingincus
+
+import (
+	"context"
+	"slices"
+	"testing"
+	"time"
+
+	"gitlab.com/gitlab-org/fleeting/fleeting/provider"
+)
+
+// waitForTemplate waits until scale ups clone the snapshot template
+func waitForTemplate(t *testing.T, g *InstanceGroup) string {
+	t.Helper()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		g.m.Lock()
+		template := g.template
+		g.m.Unlock()
+		if template != "" {
+			return template
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("snapshot template was not prepared")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestTemplateName(t *testing.T) {
+	for group, want := range map[string]string{
+		"runner-1":       "fleeting-template-runner-1",
+		"CI.example.com": "fleeting-template-ci-example-com",
+		"--odd__group--": "fleeting-template-odd-group",
+		"...":            "fleeting-template",
+	} {
+		g := &InstanceGroup{IncusGroup: group}
+		if got := g.templateName(); got != want {
+			t.Errorf("templateName() for group %q = %q, want %q", group, got, want)
+		}
+	}
+
+	g := &InstanceGroup{IncusGroup: "a-very-long-group-name-that-goes-on-and-on-and-on-and-on-forever"}
+	if got := g.templateName(); len(got) > 63 {
+		t.Errorf("templateName() = %q, longer than 63 characters", got)
+	}
+}
+
+func TestStatefulSnapshot(t *testing.T) {
+	backend := newFakeBackend()
+	g := newTestGroup(t, backend, t.TempDir(), func(g *InstanceGroup) {
+		g.IncusImage = "runner-base"
+		g.IncusStatefulSnapshot = true
+	})
+	defer shutdown(t, g)
+
+	template := waitForTemplate(t, g)
+	info, err := backend.GetInstance(context.Background(), template)
+	if err != nil || info.Status != "Stopped" {
+		t.Fatalf("template %s: %+v, %v, want a stopped VM", template, info, err)
+	}
+	if opts := backend.options(template); opts.Config["migration.stateful"] != "true" {
+		t.Errorf("template created without migration.stateful: %+v", opts.Config)
+	}
+
+	names := scaleUp(t, g, 2)
+	if len(names) != 2 {
+		t.Fatalf("reported %v, want 2 instances without the template", names)
+	}
+	for _, name := range names {
+		opts := backend.options(name)
+		if opts.Source != template || opts.Restore != templateSnapshot {
+			t.Errorf("%s: created from %q/%q, want %s/%s", name, opts.Source, opts.Restore, template, templateSnapshot)
+		}
+		if !slices.Contains(backend.snapshots(name), templateSnapshot) {
+			t.Errorf("%s: copied without the template's snapshot", name)
+		}
+
+		// Each clone gets its own identity before its own key
+		execs := backend.executed(name)
+		if len(execs) == 0 || execs[0][len(execs[0])-1] != name {
+			t.Errorf("%s: identity was not reset, commands run: %v", name, execs)
+		}
+		if len(backend.file(name, "/root/.ssh/authorized_keys")) == 0 {
+			t.Errorf("%s: no SSH key pushed", name)
+		}
+	}
+}
+
+func TestStatefulSnapshotFallsBack(t *testing.T) {
+	backend := newFakeBackend()
+	g := newTestGroup(t, backend, t.TempDir(), func(g *InstanceGroup) {
+		g.IncusStatefulSnapshot = true
+	})
+	defer shutdown(t, g)
+
+	// The template vanishes after it was prepared
+	backend.remove(waitForTemplate(t, g))
+
+	created, err := g.Increase(context.Background(), 1)
+	if err != nil || created != 1 {
+		t.Fatalf("Increase = %d, %v, want 1", created, err)
+	}
+	for name := range waitForStates(t, g, provider.StateRunning) {
+		if opts := backend.options(name); opts.Source != "" {
+			t.Errorf("%s: created from %q, want the image", name, opts.Source)
+		}
+	}
+}
+
+func TestStatefulSnapshotRebuildsOnRepublishedImage(t *testing.T) {
+	backend := newFakeBackend()
+	backend.images = map[string]string{"runner-base": "f1"}
+	dir := t.TempDir()
+	configure := func(g *InstanceGroup) {
+		g.IncusImage = "runner-base"
+		g.IncusStatefulSnapshot = true
+	}
+
+	g := newTestGroup(t, backend, dir, configure)
+	template := waitForTemplate(t, g)
+	shutdown(t, g)
+
+	// incus publish --alias runner-base moves the alias to a new image while the plugin is down
+	backend.mu.Lock()
+	backend.images["runner-base"] = "f2"
+	creates := backend.creates
+	backend.mu.Unlock()
+
+	g = newTestGroup(t, backend, dir, configure)
+	defer shutdown(t, g)
+
+	if got := waitForTemplate(t, g); got != template {
+		t.Fatalf("template %s, want %s", got, template)
+	}
+	if vm, _ := backend.GetInstance(context.Background(), template); vm.BaseImage != "f2" {
+		t.Errorf("template built from image %q, want f2", vm.BaseImage)
+	}
+	backend.mu.Lock()
+	defer backend.mu.Unlock()
+	if backend.creates != creates+1 {
+		t.Errorf("%d instances created on restart, want the rebuilt template only", backend.creates-creates)
+	}
+}